@@ -0,0 +1,125 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// getCacheDir returns the path to the app cache directory. Like
+// getConfigDir, the directory isn't created.
+func getCacheDir() (string, error) {
+	cacheHome := os.Getenv("XDG_CACHE_HOME")
+	if cacheHome == "" {
+		homeDir, err := os.UserHomeDir()
+		if err != nil {
+			return "", errors.Wrap(err, "could not determine home directory")
+		}
+		cacheHome = filepath.Join(homeDir, ".cache")
+	}
+
+	return filepath.Join(cacheHome, "lyrics"), nil
+}
+
+// cacheEntry is a cached lyrics file
+type cacheEntry struct {
+	Artist    string    `json:"artist"`
+	Title     string    `json:"title"`
+	Lyrics    string    `json:"lyrics"`
+	FetchedAt time.Time `json:"fetched_at"`
+}
+
+// lyricsCache stores fetched lyrics on disk, one JSON file per song, so that
+// songs don't have to be looked up again. A nil lyricsCache caches nothing.
+type lyricsCache struct {
+	dir string
+
+	// Set for refreshes, which fetch lyrics again but still update the
+	// cache
+	writeOnly bool
+}
+
+func newLyricsCache(dir string) *lyricsCache {
+	c := &lyricsCache{
+		dir: dir,
+	}
+	return c
+}
+
+// forRefresh returns a cache that misses on every lookup, so lyrics are
+// fetched again, but still stores what was fetched
+func (c *lyricsCache) forRefresh() *lyricsCache {
+	if c == nil {
+		return nil
+	}
+	return &lyricsCache{dir: c.dir, writeOnly: true}
+}
+
+// path returns the cache file for the song. Names are hashed since tags can
+// contain characters that aren't valid in file names.
+func (c *lyricsCache) path(artist, title string) string {
+	sum := sha256.Sum256([]byte(normalizeSongIDField(artist) + songIDSeparator + normalizeSongIDField(title)))
+	return filepath.Join(c.dir, hex.EncodeToString(sum[:])+".json")
+}
+
+// get returns the cached lyrics for the song, if any. Unreadable entries are
+// treated as missing.
+func (c *lyricsCache) get(artist, title string) (cacheEntry, bool) {
+	if c == nil || c.writeOnly {
+		return cacheEntry{}, false
+	}
+
+	data, err := os.ReadFile(c.path(artist, title))
+	if err != nil {
+		return cacheEntry{}, false
+	}
+
+	var entry cacheEntry
+	if err := json.Unmarshal(data, &entry); err != nil || entry.Lyrics == "" {
+		return cacheEntry{}, false
+	}
+	return entry, true
+}
+
+// put stores the lyrics for the song, replacing the file atomically so that
+// readers never see a partial entry
+func (c *lyricsCache) put(artist, title, lyrics string) error {
+	if c == nil {
+		return nil
+	}
+
+	data, err := json.Marshal(cacheEntry{
+		Artist:    artist,
+		Title:     title,
+		Lyrics:    lyrics,
+		FetchedAt: time.Now(),
+	})
+	if err != nil {
+		return errors.Wrap(err, "encode cache entry")
+	}
+
+	if err := os.MkdirAll(c.dir, 0755); err != nil {
+		return errors.Wrap(err, "create cache directory")
+	}
+
+	path := c.path(artist, title)
+	tmp, err := os.CreateTemp(c.dir, filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return errors.Wrap(err, "write cache entry")
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return errors.Wrap(err, "write cache entry")
+	}
+	if err := tmp.Close(); err != nil {
+		return errors.Wrap(err, "write cache entry")
+	}
+	return errors.Wrap(os.Rename(tmp.Name(), path), "write cache entry")
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestGetCacheDir(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", "/tmp/cache-home")
+	dir, err := getCacheDir()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "/tmp/cache-home/lyrics"; dir != want {
+		t.Errorf("getCacheDir() = %q, want %q", dir, want)
+	}
+}
+
+func TestLyricsCache(t *testing.T) {
+	c := newLyricsCache(filepath.Join(t.TempDir(), "lyrics"))
+
+	if _, ok := c.get("Artist", "Title"); ok {
+		t.Fatal("get hit on an empty cache")
+	}
+
+	before := time.Now()
+	if err := c.put("Artist", "Title", "Some lyrics"); err != nil {
+		t.Fatalf("put: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		artist string
+		title  string
+		wantOK bool
+	}{
+		{"same song", "Artist", "Title", true},
+		{"different case and spacing", " artist ", "TITLE", true},
+		{"different title", "Artist", "Other Title", false},
+		{"different artist", "Other Artist", "Title", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entry, ok := c.get(tt.artist, tt.title)
+			if ok != tt.wantOK {
+				t.Fatalf("get(%q, %q) hit = %v, want %v", tt.artist, tt.title, ok, tt.wantOK)
+			}
+			if !ok {
+				return
+			}
+			if entry.Lyrics != "Some lyrics" {
+				t.Errorf("lyrics = %q, want %q", entry.Lyrics, "Some lyrics")
+			}
+			if entry.FetchedAt.Before(before) {
+				t.Errorf("fetched at %v, before the put at %v", entry.FetchedAt, before)
+			}
+		})
+	}
+}
+
+func TestLyricsCacheIgnoresCorruptEntries(t *testing.T) {
+	c := newLyricsCache(t.TempDir())
+	if err := os.WriteFile(c.path("Artist", "Title"), []byte("{not json"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := c.get("Artist", "Title"); ok {
+		t.Error("get hit on a corrupt entry")
+	}
+}
+
+func TestLyricsCacheForRefresh(t *testing.T) {
+	c := newLyricsCache(t.TempDir())
+	if err := c.put("Artist", "Title", "Old lyrics"); err != nil {
+		t.Fatal(err)
+	}
+
+	refresh := c.forRefresh()
+	if _, ok := refresh.get("Artist", "Title"); ok {
+		t.Error("refresh cache hit, want a miss so the lyrics are fetched again")
+	}
+	if err := refresh.put("Artist", "Title", "New lyrics"); err != nil {
+		t.Fatal(err)
+	}
+	if entry, _ := c.get("Artist", "Title"); entry.Lyrics != "New lyrics" {
+		t.Errorf("lyrics after refresh = %q, want %q", entry.Lyrics, "New lyrics")
+	}
+}
+
+func TestNilLyricsCache(t *testing.T) {
+	var c *lyricsCache
+	if err := c.put("Artist", "Title", "Lyrics"); err != nil {
+		t.Errorf("put: %v", err)
+	}
+	if _, ok := c.get("Artist", "Title"); ok {
+		t.Error("nil cache hit")
+	}
+}
+
+func TestChainProviderUsesCache(t *testing.T) {
+	remote := &stubProvider{lyrics: "Fetched lyrics"}
+	chain := NewChainProvider(newLyricsCache(t.TempDir()))
+	chain.AddRemote("remote", remote)
+
+	for i := 0; i < 2; i++ {
+		lyrics, err := chain.GetLyrics(context.Background(), "Artist", "", "Title")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if lyrics != "Fetched lyrics" {
+			t.Errorf("lyrics = %q, want %q", lyrics, "Fetched lyrics")
+		}
+	}
+	if remote.calls != 1 {
+		t.Errorf("remote provider called %d times, want 1 with the second lookup cached", remote.calls)
+	}
+	if !chain.isCached("Artist", "Title") {
+		t.Error("isCached = false after fetching")
+	}
+}
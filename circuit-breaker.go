@@ -0,0 +1,128 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Defaults for the lyrics provider circuit breakers
+const (
+	breakerFailureThreshold = 3
+	breakerCooldown         = 5 * time.Minute
+)
+
+// circuitBreaker skips a lyrics provider that keeps failing. After threshold
+// consecutive failures the provider is skipped until the cooldown has passed,
+// after which a single request is let through as a probe. A successful probe
+// closes the breaker again, while a failed one restarts the cooldown.
+//
+// A nil circuitBreaker never skips the provider.
+type circuitBreaker struct {
+	name      string
+	threshold int
+	cooldown  time.Duration
+
+	mu        sync.Mutex
+	failures  int
+	openUntil time.Time
+	probing   bool
+}
+
+func newCircuitBreaker(name string) *circuitBreaker {
+	b := &circuitBreaker{
+		name:      name,
+		threshold: breakerFailureThreshold,
+		cooldown:  breakerCooldown,
+	}
+	return b
+}
+
+// allow reports whether the provider should be tried
+func (b *circuitBreaker) allow() bool {
+	if b == nil {
+		return true
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.failures < b.threshold {
+		return true
+	}
+
+	// Let a single probe through once the cooldown has passed
+	if time.Now().After(b.openUntil) && !b.probing {
+		b.probing = true
+		return true
+	}
+	return false
+}
+
+// record updates the breaker with the outcome of a request to the provider
+func (b *circuitBreaker) record(failed bool) {
+	if b == nil {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.probing = false
+	if !failed {
+		b.failures = 0
+		return
+	}
+
+	b.failures++
+	if b.failures >= b.threshold {
+		b.openUntil = time.Now().Add(b.cooldown)
+	}
+}
+
+// cancel releases a probe whose request was cancelled before it finished,
+// without counting it as a failure, so that the next request can probe
+func (b *circuitBreaker) cancel() {
+	if b == nil {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.probing = false
+}
+
+// skippedError returns the error reported in place of the provider's result
+// while it is being skipped
+func (b *circuitBreaker) skippedError() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	// Once the cooldown has passed, a probe is already in flight
+	remaining := time.Until(b.openUntil).Round(time.Second)
+	if remaining <= 0 {
+		return fmt.Errorf("%s skipped after %d failures in a row, probe in progress",
+			b.name, b.failures)
+	}
+	return fmt.Errorf("%s skipped after %d failures in a row, retrying in %s",
+		b.name, b.failures, remaining)
+}
+
+// String describes the state of the breaker for the debug footer
+func (b *circuitBreaker) String() string {
+	if b == nil {
+		return ""
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch {
+	case b.failures < b.threshold:
+		return fmt.Sprintf("%s: ok", b.name)
+	case time.Now().After(b.openUntil):
+		return fmt.Sprintf("%s: probing", b.name)
+	default:
+		return fmt.Sprintf("%s: skipped for %s", b.name, time.Until(b.openUntil).Round(time.Second))
+	}
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// openBreaker returns a breaker that has tripped and whose cooldown has
+// passed, so that the next request is a probe
+func openBreaker() *circuitBreaker {
+	b := newCircuitBreaker("test")
+	for i := 0; i < b.threshold; i++ {
+		b.record(true)
+	}
+	b.openUntil = time.Now().Add(-time.Second)
+	return b
+}
+
+func TestCircuitBreakerOpensAfterThreshold(t *testing.T) {
+	b := newCircuitBreaker("test")
+	for i := 0; i < b.threshold; i++ {
+		if !b.allow() {
+			t.Fatalf("allow() = false after %d failures", i)
+		}
+		b.record(true)
+	}
+	if b.allow() {
+		t.Fatal("allow() = true during the cooldown")
+	}
+}
+
+func TestCircuitBreakerProbe(t *testing.T) {
+	tests := []struct {
+		name      string
+		finish    func(b *circuitBreaker)
+		wantAllow bool
+	}{
+		{"successful probe closes the breaker", func(b *circuitBreaker) { b.record(false) }, true},
+		{"failed probe restarts the cooldown", func(b *circuitBreaker) { b.record(true) }, false},
+		{"cancelled probe allows another probe", func(b *circuitBreaker) { b.cancel() }, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := openBreaker()
+			if !b.allow() {
+				t.Fatal("probe not allowed after the cooldown")
+			}
+			if b.allow() {
+				t.Fatal("second request allowed while probing")
+			}
+
+			tt.finish(b)
+			if got := b.allow(); got != tt.wantAllow {
+				t.Errorf("allow() = %v, want %v", got, tt.wantAllow)
+			}
+		})
+	}
+}
+
+func TestCircuitBreakerCancelDoesNotCountAsFailure(t *testing.T) {
+	b := newCircuitBreaker("test")
+	b.record(true)
+	b.cancel()
+	if b.failures != 1 {
+		t.Errorf("failures = %d, want 1", b.failures)
+	}
+}
+
+func TestCircuitBreakerSkippedError(t *testing.T) {
+	b := openBreaker()
+	b.allow()
+	if got, want := b.skippedError().Error(), "test skipped after 3 failures in a row, probe in progress"; got != want {
+		t.Errorf("skippedError() while probing = %q, want %q", got, want)
+	}
+
+	b.record(true)
+	if got, want := b.skippedError().Error(), "test skipped after 4 failures in a row, retrying in 5m0s"; got != want {
+		t.Errorf("skippedError() during the cooldown = %q, want %q", got, want)
+	}
+}
+
+// cancelledProvider fails as if the fetch was cancelled by a song change
+type cancelledProvider struct{}
+
+func (cancelledProvider) GetLyrics(ctx context.Context, artist, album, title string) (string, error) {
+	return "", errors.Wrap(context.Canceled, "fetch")
+}
+
+func TestChainProviderReleasesCancelledProbe(t *testing.T) {
+	chain := NewChainProvider(nil)
+	chain.AddRemote("test", cancelledProvider{})
+	chain.entries[0].breaker = openBreaker()
+
+	for i := 0; i < 2; i++ {
+		_, err := chain.GetLyrics(context.Background(), "Artist", "", "Title")
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("attempt %d: err = %v, want context.Canceled", i, err)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"os/exec"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/pkg/errors"
+)
+
+// Clipboard copies text to the system clipboard
+type Clipboard interface {
+	Copy(text string) error
+}
+
+// commandClipboard copies text by piping it to a clipboard tool
+type commandClipboard struct {
+	name string
+	args []string
+}
+
+// clipboardCommands are the clipboard tools to look for, in order
+var clipboardCommands = []commandClipboard{
+	{name: "wl-copy"},
+	{name: "xclip", args: []string{"-selection", "clipboard"}},
+	{name: "xsel", args: []string{"--clipboard", "--input"}},
+	{name: "pbcopy"},
+}
+
+// findClipboard returns the first clipboard tool that's installed, or nil if
+// there's none
+func findClipboard() Clipboard {
+	for _, c := range clipboardCommands {
+		if _, err := exec.LookPath(c.name); err == nil {
+			return &c
+		}
+	}
+	return nil
+}
+
+// Copy pipes the text to the tool. Its output isn't captured, since tools
+// like xclip stay running in the background to serve the selection and
+// would hold the pipe open.
+func (c *commandClipboard) Copy(text string) error {
+	cmd := exec.Command(c.name, c.args...)
+	cmd.Stdin = strings.NewReader(text)
+	return errors.Wrap(cmd.Run(), c.name)
+}
+
+// copiedMsg reports the result of copying to the clipboard
+type copiedMsg struct {
+	err error
+}
+
+// copyToClipboardCmd is a command to copy the text to the clipboard
+func copyToClipboardCmd(clipboard Clipboard, text string) tea.Cmd {
+	return func() tea.Msg {
+		return copiedMsg{err: clipboard.Copy(text)}
+	}
+}
+
+// copyLyrics copies the displayed lyrics to the clipboard
+func (m *model) copyLyrics() tea.Cmd {
+	if m.state != "" || m.lyricsSongID == "" {
+		return m.flashFooterMessage("No lyrics to copy")
+	}
+	if m.clipboard == nil {
+		return m.flashFooterMessage("No clipboard tool found; install wl-copy, xclip, xsel or pbcopy")
+	}
+	return copyToClipboardCmd(m.clipboard, m.lyrics)
+}
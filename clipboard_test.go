@@ -0,0 +1,133 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/pkg/errors"
+)
+
+// fakeClipboard records what was copied, or fails with err
+type fakeClipboard struct {
+	copied []string
+	err    error
+}
+
+func (c *fakeClipboard) Copy(text string) error {
+	if c.err != nil {
+		return c.err
+	}
+	c.copied = append(c.copied, text)
+	return nil
+}
+
+// pressCopy presses y and sends the result of the copy back to the model
+func pressCopy(t *testing.T, m model) model {
+	t.Helper()
+	updated, cmd := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("y")})
+	m = updated.(model)
+	for _, msg := range runCmd(cmd) {
+		if msg, ok := msg.(copiedMsg); ok {
+			m = update(t, m, msg)
+		}
+	}
+	return m
+}
+
+// runCmd runs the command and any commands it batches, and returns the
+// messages they send promptly. Ticks, such as the one clearing the footer
+// message, are left running.
+func runCmd(cmd tea.Cmd) []tea.Msg {
+	if cmd == nil {
+		return nil
+	}
+	done := make(chan tea.Msg, 1)
+	go func() { done <- cmd() }()
+
+	var msg tea.Msg
+	select {
+	case msg = <-done:
+	case <-time.After(100 * time.Millisecond):
+		return nil
+	}
+
+	batch, ok := msg.(tea.BatchMsg)
+	if !ok {
+		return []tea.Msg{msg}
+	}
+	var msgs []tea.Msg
+	for _, cmd := range batch {
+		msgs = append(msgs, runCmd(cmd)...)
+	}
+	return msgs
+}
+
+func TestCopyLyrics(t *testing.T) {
+	clipboard := &fakeClipboard{}
+	m := withSong(newTestModel(80, 24), "Artist", "Album", "Title")
+	m.clipboard = clipboard
+	m = update(t, m, songLyricsMsg{artist: "Artist", album: "Album", title: "Title", lyrics: "First line\nSecond line"})
+
+	m = pressCopy(t, m)
+	if len(clipboard.copied) != 1 || clipboard.copied[0] != "First line\nSecond line" {
+		t.Errorf("copied %q, want the lyrics", clipboard.copied)
+	}
+	if m.footerMessage != "Copied!" {
+		t.Errorf("footer message = %q, want %q", m.footerMessage, "Copied!")
+	}
+}
+
+func TestCopyLyricsErrors(t *testing.T) {
+	tests := []struct {
+		name        string
+		clipboard   Clipboard
+		lyrics      bool
+		wantMessage string
+	}{
+		{"no clipboard tool", nil, true, "No clipboard tool found; install wl-copy, xclip, xsel or pbcopy"},
+		{"copy fails", &fakeClipboard{err: errors.New("xclip: exit status 1")}, true, "Error copying lyrics: xclip: exit status 1"},
+		{"no lyrics shown", &fakeClipboard{}, false, "No lyrics to copy"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := withSong(newTestModel(80, 24), "Artist", "Album", "Title")
+			m.clipboard = tt.clipboard
+			if tt.lyrics {
+				m = update(t, m, songLyricsMsg{artist: "Artist", album: "Album", title: "Title", lyrics: "Lyrics"})
+			}
+
+			m = pressCopy(t, m)
+			if m.footerMessage != tt.wantMessage {
+				t.Errorf("footer message = %q, want %q", m.footerMessage, tt.wantMessage)
+			}
+			if c, ok := tt.clipboard.(*fakeClipboard); ok && len(c.copied) > 0 {
+				t.Errorf("copied %q, want nothing copied", c.copied)
+			}
+		})
+	}
+}
+
+func TestCommandClipboard(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "clipboard")
+	c := &commandClipboard{name: "sh", args: []string{"-c", `cat > "$0"`, path}}
+
+	if err := c.Copy("Some lyrics"); err != nil {
+		t.Fatal(err)
+	}
+	copied, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(copied) != "Some lyrics" {
+		t.Errorf("copied %q, want %q", copied, "Some lyrics")
+	}
+
+	missing := &commandClipboard{name: "no-such-clipboard-tool"}
+	if err := missing.Copy("Some lyrics"); err == nil {
+		t.Error("Copy with a missing tool succeeded, want an error")
+	}
+}
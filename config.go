@@ -2,19 +2,218 @@ package main
 
 import (
 	"encoding/json"
+	"log"
 	"os"
 	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
 
 	"github.com/pkg/errors"
 )
 
+// geniusTokenEnvVar is the environment variable that the access token can
+// be set in, taking precedence over the config file
+const geniusTokenEnvVar = "GENIUS_ACCESS_TOKEN"
+
+// tokenResolutionOrder describes where the access token is looked for, in
+// order of precedence
+const tokenResolutionOrder = "the " + geniusTokenEnvVar + " environment variable, " +
+	"then genius_access_token in config.json, then the file named by genius_access_token_file, " +
+	"then the system keyring (see lyrics set-token)"
+
 // Config holds the application configuration
 type Config struct {
+	// GeniusAccessToken is the Genius API access token. The
+	// GENIUS_ACCESS_TOKEN environment variable takes precedence over it.
 	GeniusAccessToken string `json:"genius_access_token"`
+
+	// GeniusAccessTokenFile is a file containing the access token, so that it
+	// can have stricter permissions than the config file. It is only read if
+	// GeniusAccessToken is empty.
+	GeniusAccessTokenFile string `json:"genius_access_token_file"`
+
+	// ScrapeHeaders are extra HTTP headers sent when scraping the lyrics
+	// page. They override the built-in defaults.
+	ScrapeHeaders map[string]string `json:"scrape_headers"`
+
+	// EnableSelectedTrack allows pressing "t" to show lyrics for the track
+	// selected in cmus rather than the one that is playing
+	EnableSelectedTrack bool `json:"enable_selected_track"`
+
+	// MaxLyricsChars truncates lyrics longer than this many characters. Zero
+	// means no limit.
+	MaxLyricsChars int `json:"max_lyrics_chars"`
+
+	// Alignment aligns the lyrics "center", "left" or "right". Defaults to
+	// center. The --align flag overrides it.
+	Alignment string `json:"alignment"`
+
+	// Keybindings maps actions such as "scroll_down" to the key that
+	// triggers them, in place of the default keys. See actions.
+	Keybindings map[string]string `json:"keybindings"`
+
+	// Theme overrides the colors of the UI, or selects a built-in preset
+	Theme ThemeConfig `json:"theme"`
+
+	// ColorScheme selects the "light" or "dark" palette. Defaults to "auto",
+	// which detects the terminal background.
+	ColorScheme string `json:"color_scheme"`
+
+	// BlankInitialState shows an empty screen instead of "Loading..." until
+	// the first song is detected
+	BlankInitialState bool `json:"blank_initial_state"`
+
+	// NoSongGraceSeconds is how long cmus must report no song before "No
+	// song playing" is shown. Defaults to 2.
+	NoSongGraceSeconds int `json:"no_song_grace_seconds"`
+
+	// Player is the music player whose song is shown: "cmus", "mpd", or
+	// "playerctl" for any MPRIS player. Defaults to cmus. The --player flag overrides it.
+	Player string `json:"player"`
+
+	// PollIntervalSeconds is how often cmus is checked for song changes.
+	// Defaults to 5.
+	PollIntervalSeconds int `json:"poll_interval_seconds"`
+
+	// FetchDebounceMillis is how long a song must play before its lyrics are
+	// fetched, so that songs skipped past quickly aren't looked up. Defaults
+	// to 1500; zero fetches straight away.
+	FetchDebounceMillis int `json:"fetch_debounce_ms"`
+
+	// PauseStopsPolling stops polling cmus while playback is paused, until
+	// a key is pressed. cmus is still checked once a minute.
+	PauseStopsPolling bool `json:"pause_stops_polling"`
+
+	// CacheLyrics stores lyrics fetched from Genius on disk, so that songs
+	// don't have to be looked up again. Defaults to true.
+	CacheLyrics bool `json:"cache_lyrics"`
+
+	// RefreshPreservesScroll keeps the scroll position when the lyrics are
+	// refreshed with "r", rather than going back to the top. Defaults to
+	// true.
+	RefreshPreservesScroll bool `json:"refresh_preserves_scroll"`
+
+	// ShowLineNumbers shows line numbers to the left of the lyrics on
+	// startup. They can be toggled with "#".
+	ShowLineNumbers bool `json:"show_line_numbers"`
+
+	// LocalLyricsDir is a directory of "Artist - Title.lrc" or ".txt" files,
+	// used by the "local" provider
+	LocalLyricsDir string `json:"local_lyrics_dir"`
+
+	// Providers are the lyrics providers to try, in order: "local",
+	// "genius" and "lrclib". Defaults to local, then Genius, then LRCLIB.
+	Providers []string `json:"providers"`
+
+	// VerticalCenter vertically centers lyrics that are shorter than the
+	// window
+	VerticalCenter bool `json:"vertical_center"`
+
+	// BoldLyrics renders lyrics in bold on startup. It can be toggled with
+	// "b".
+	BoldLyrics bool `json:"bold_lyrics"`
+
+	// AlbumSearchMode controls how the album is used when searching Genius:
+	// "query" adds it to the search query, "rerank" prefers hits from the
+	// same album, and "off" ignores it. Defaults to "rerank".
+	AlbumSearchMode string `json:"album_search_mode"`
+
+	// RequestTimeoutSeconds limits each request to a lyrics provider.
+	// Defaults to 10.
+	RequestTimeoutSeconds int `json:"request_timeout_seconds"`
+
+	// RetryEmptyScrape scrapes the lyrics page once more when its lyrics
+	// container is present but empty. Defaults to true.
+	RetryEmptyScrape bool `json:"retry_empty_scrape"`
+
+	// SearchQueryTemplate builds the Genius search query from the
+	// {artist}, {album} and {title} placeholders. Defaults to
+	// "{artist} {title}".
+	SearchQueryTemplate string `json:"search_query_template"`
+
+	// PlaceholderArtists are artists such as "Various Artists" that aren't
+	// searched for. The album artist or composer is used instead, or just the
+	// title if neither is usable.
+	PlaceholderArtists []string `json:"placeholder_artists"`
+
+	// LyricLineFilters are regular expressions whose matches are removed
+	// from each scraped lyric line. Lines left empty are dropped. Defaults to
+	// removing leading timestamps.
+	LyricLineFilters []string `json:"lyric_line_filters"`
+
+	// SongChangeCmd is a shell command run whenever the song changes. See
+	// runSongChangeHookCmd for how the song info is passed to it.
+	SongChangeCmd string `json:"song_change_cmd"`
+
+	// ShowSectionHeaders keeps section headers such as "[Chorus]" in the
+	// lyrics. Defaults to true.
+	ShowSectionHeaders bool `json:"show_section_headers"`
+
+	// ShowPlaySource shows in the status bar whether cmus is playing from
+	// the library or a playlist
+	ShowPlaySource bool `json:"show_play_source"`
+
+	// StateMessages replaces the text shown for the "no_song", "loading",
+	// "error" and "empty" states. "{error}" is replaced by the error.
+	StateMessages map[string]string `json:"state_messages"`
+
+	// StateColors sets the text color of the states, such as "#FF5F5F"
+	StateColors map[string]string `json:"state_colors"`
+
+	// SavePathTemplate is where "s" saves the lyrics, with {artist},
+	// {album} and {title} placeholders. Defaults to
+	// "~/.local/share/lyrics/{artist} - {title}.txt".
+	SavePathTemplate string `json:"save_path_template"`
+
+	// HistoryFile is a log that each played song is appended to
+	HistoryFile string `json:"history_file"`
+
+	// HistoryFormat is the format of the history log, "jsonl" or "csv".
+	// Defaults to "jsonl".
+	HistoryFormat string `json:"history_format"`
 }
 
-// getConfigPath returns the path to the config file
-func getConfigPath() (string, error) {
+// defaultPollIntervalSeconds is how often cmus is checked unless configured
+// otherwise
+const defaultPollIntervalSeconds = 5
+
+// resolveAccessToken sets the access token from the first of the places in
+// tokenResolutionOrder that has one
+func (c *Config) resolveAccessToken() error {
+	if token := strings.TrimSpace(os.Getenv(geniusTokenEnvVar)); token != "" {
+		c.GeniusAccessToken = token
+		return nil
+	}
+
+	if c.GeniusAccessToken == "" && c.GeniusAccessTokenFile != "" {
+		token, err := os.ReadFile(expandHome(c.GeniusAccessTokenFile))
+		if err != nil {
+			return errors.Wrap(err, "read genius_access_token_file")
+		}
+		c.GeniusAccessToken = strings.TrimSpace(string(token))
+	}
+
+	if c.GeniusAccessToken == "" {
+		c.GeniusAccessToken = keyringToken()
+	}
+	return nil
+}
+
+// expandHome expands a leading ~ in path to the home directory
+func expandHome(path string) string {
+	if strings.HasPrefix(path, "~/") {
+		if homeDir, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(homeDir, path[2:])
+		}
+	}
+	return path
+}
+
+// getConfigDir returns the path to the app config directory. The directory
+// isn't created, so that an existing config can still be read on read-only
+// file systems; code that writes to it must create it first.
+func getConfigDir() (string, error) {
 	// Check XDG_CONFIG_HOME first
 	configHome := os.Getenv("XDG_CONFIG_HOME")
 	if configHome == "" {
@@ -26,18 +225,36 @@ func getConfigPath() (string, error) {
 		configHome = filepath.Join(homeDir, ".config")
 	}
 
-	// Create app config directory if it doesn't exist
-	appConfigDir := filepath.Join(configHome, "lyrics")
-	if err := os.MkdirAll(appConfigDir, 0755); err != nil {
-		return "", errors.Wrap(err, "create config directory")
-	}
+	return filepath.Join(configHome, "lyrics"), nil
+}
 
-	return filepath.Join(appConfigDir, "config.json"), nil
+// getConfigPath returns the path to the config file
+func getConfigPath() (string, error) {
+	configDir, err := getConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(configDir, "config.json"), nil
 }
 
 // LoadConfig loads the configuration from the config file
 func LoadConfig() (Config, error) {
-	var config Config
+	// Defaults for fields that aren't set in the config file
+	config := Config{
+		Player:                 playerCmus,
+		NoSongGraceSeconds:     2,
+		RequestTimeoutSeconds:  10,
+		FetchDebounceMillis:    1500,
+		PollIntervalSeconds:    defaultPollIntervalSeconds,
+		Alignment:              alignCenter,
+		RetryEmptyScrape:       true,
+		ShowSectionHeaders:     true,
+		RefreshPreservesScroll: true,
+		CacheLyrics:            true,
+		Providers:              []string{providerLocal, providerGenius, providerLRCLIB},
+		PlaceholderArtists:     []string{"Various Artists", "Various", "VA", "Soundtrack", "Original Soundtrack"},
+		LyricLineFilters:       []string{`^\s*\[\d{1,2}:\d{2}(?:[.:]\d{1,3})?\]\s*`},
+	}
 
 	configPath, err := getConfigPath()
 	if err != nil {
@@ -48,8 +265,9 @@ func LoadConfig() (Config, error) {
 	data, err := os.ReadFile(configPath)
 	if err != nil {
 		if os.IsNotExist(err) {
-			// Config file doesn't exist yet, which is okay
-			return config, nil
+			// Config file doesn't exist yet, which is okay, as the token
+			// may still be in the environment
+			return config, config.resolveAccessToken()
 		}
 		return config, errors.Wrap(err, "read config file")
 	}
@@ -58,5 +276,157 @@ func LoadConfig() (Config, error) {
 		return config, errors.Wrap(err, "parse config file")
 	}
 
+	if err := config.resolveAccessToken(); err != nil {
+		return config, err
+	}
+
+	if _, err := parseAlignment(config.Alignment); err != nil {
+		return config, errors.Wrap(err, "invalid alignment")
+	}
+
+	if _, err := resolveKeybindings(config.Keybindings); err != nil {
+		return config, err
+	}
+
+	if err := config.Theme.validate(); err != nil {
+		return config, err
+	}
+
+	switch config.ColorScheme {
+	case "", "auto", "light", "dark":
+	default:
+		return config, errors.Errorf("invalid color_scheme %q: must be auto, light, or dark", config.ColorScheme)
+	}
+
+	for _, filter := range config.LyricLineFilters {
+		if _, err := regexp.Compile(filter); err != nil {
+			return config, errors.Wrapf(err, "invalid lyric_line_filters entry %q", filter)
+		}
+	}
+
+	for _, states := range []map[string]string{config.StateMessages, config.StateColors} {
+		for state := range states {
+			if !isState(state) {
+				return config, errors.Errorf("invalid state %q in state_messages or state_colors: must be no_song, loading, error, empty, or cancelled", state)
+			}
+		}
+	}
+	for state, color := range config.StateColors {
+		if color != "" && !isValidColor(color) {
+			return config, errors.Errorf("invalid state_colors.%s %q: must be a hex color such as \"#FF5F5F\" or an ANSI color number", state, color)
+		}
+	}
+
+	switch config.Player {
+	case playerCmus, playerMPD, playerPlayerctl:
+	default:
+		return config, errors.Errorf("invalid player %q: must be cmus, mpd, or playerctl", config.Player)
+	}
+
+	// A bad interval isn't worth refusing to start over
+	if config.PollIntervalSeconds <= 0 {
+		log.Printf("Warning: invalid poll_interval_seconds %d: must be positive, using %d",
+			config.PollIntervalSeconds, defaultPollIntervalSeconds)
+		config.PollIntervalSeconds = defaultPollIntervalSeconds
+	}
+
+	if config.FetchDebounceMillis < 0 {
+		return config, errors.Errorf("invalid fetch_debounce_ms %d: must not be negative", config.FetchDebounceMillis)
+	}
+
+	if config.RequestTimeoutSeconds < 0 {
+		return config, errors.Errorf("invalid request_timeout_seconds %d: must not be negative", config.RequestTimeoutSeconds)
+	}
+
+	for _, provider := range config.Providers {
+		switch provider {
+		case providerLocal, providerGenius, providerLRCLIB:
+		default:
+			return config, errors.Errorf("invalid providers entry %q: must be local, genius, or lrclib", provider)
+		}
+	}
+
+	switch config.HistoryFormat {
+	case "", historyFormatJSONL, historyFormatCSV:
+	default:
+		return config, errors.Errorf("invalid history_format %q: must be jsonl or csv", config.HistoryFormat)
+	}
+
+	switch config.AlbumSearchMode {
+	case "", albumSearchModeQuery, albumSearchModeRerank, albumSearchModeOff:
+	default:
+		return config, errors.Errorf("invalid album_search_mode %q: must be query, rerank, or off", config.AlbumSearchMode)
+	}
+
 	return config, nil
 }
+
+// newGeniusAPIClient creates a Genius client using the settings in config
+func newGeniusAPIClient(config Config) *GeniusAPIClient {
+	// The filters were validated when loading the config
+	var lyricLineFilters []*regexp.Regexp
+	for _, filter := range config.LyricLineFilters {
+		lyricLineFilters = append(lyricLineFilters, regexp.MustCompile(filter))
+	}
+
+	return NewGeniusAPIClient(config.GeniusAccessToken, GeniusAPIClientOptions{
+		ScrapeHeaders:       config.ScrapeHeaders,
+		AlbumSearchMode:     config.AlbumSearchMode,
+		RetryEmptyScrape:    config.RetryEmptyScrape,
+		SearchQueryTemplate: config.SearchQueryTemplate,
+		LyricLineFilters:    lyricLineFilters,
+		Timeout:             time.Duration(config.RequestTimeoutSeconds) * time.Second,
+	})
+}
+
+// needsAccessToken reports whether Genius is the only provider that can find
+// lyrics, so that lookups are bound to fail without an access token
+func (c Config) needsAccessToken() bool {
+	needed := false
+	for _, name := range c.Providers {
+		switch name {
+		case providerGenius:
+			needed = true
+		case providerLRCLIB:
+			return false
+		case providerLocal:
+			if c.LocalLyricsDir != "" {
+				return false
+			}
+		}
+	}
+	return needed
+}
+
+// newLyricsProvider creates the chain of lyrics providers configured in
+// config. The local provider is skipped if no directory is configured.
+func newLyricsProvider(config Config, geniusAPIClient *GeniusAPIClient) *ChainProvider {
+	chain := NewChainProvider(newLyricsCacheFromConfig(config))
+	for _, name := range config.Providers {
+		switch name {
+		case providerLocal:
+			if config.LocalLyricsDir != "" {
+				chain.AddLocal(name, NewLocalFileProvider(config.LocalLyricsDir))
+			}
+		case providerGenius:
+			chain.AddRemote(name, geniusAPIClient)
+		case providerLRCLIB:
+			chain.AddRemote(name, NewLRCLIBClient(time.Duration(config.RequestTimeoutSeconds)*time.Second))
+		}
+	}
+	return chain
+}
+
+// newLyricsCacheFromConfig creates the lyrics cache, or returns nil if caching
+// is disabled or there's no cache directory
+func newLyricsCacheFromConfig(config Config) *lyricsCache {
+	if !config.CacheLyrics {
+		return nil
+	}
+
+	dir, err := getCacheDir()
+	if err != nil {
+		return nil
+	}
+	return newLyricsCache(dir)
+}
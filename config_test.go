@@ -0,0 +1,174 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// writeConfig points the config directory at a temporary one holding the
+// config, or no config file if it's empty
+func writeConfig(t *testing.T, config string) {
+	t.Helper()
+	configHome := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", configHome)
+	if config == "" {
+		return
+	}
+
+	dir := filepath.Join(configHome, "lyrics")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "config.json"), []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// withoutKeyring hides the keyring tools, so that a token stored on the
+// machine running the tests isn't picked up
+func withoutKeyring(t *testing.T) {
+	t.Setenv("PATH", t.TempDir())
+}
+
+func TestLoadConfigAccessToken(t *testing.T) {
+	tests := []struct {
+		name      string
+		config    string
+		env       string
+		wantToken string
+	}{
+		{"neither", "", "", ""},
+		{"config only", `{"genius_access_token": "config-token"}`, "", "config-token"},
+		{"env only", "", "env-token", "env-token"},
+		{"env takes precedence over config", `{"genius_access_token": "config-token"}`, "env-token", "env-token"},
+		{"blank env is ignored", `{"genius_access_token": "config-token"}`, "  ", "config-token"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withoutKeyring(t)
+			writeConfig(t, tt.config)
+			t.Setenv(geniusTokenEnvVar, tt.env)
+
+			config, err := LoadConfig()
+			if err != nil {
+				t.Fatalf("LoadConfig: %v", err)
+			}
+			if config.GeniusAccessToken != tt.wantToken {
+				t.Errorf("token = %q, want %q", config.GeniusAccessToken, tt.wantToken)
+			}
+		})
+	}
+}
+
+func TestLoadConfigAccessTokenFile(t *testing.T) {
+	withoutKeyring(t)
+	t.Setenv(geniusTokenEnvVar, "")
+
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(tokenFile, []byte("file-token\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		config    string
+		wantToken string
+		wantErr   bool
+	}{
+		{"read when no token is set", `{"genius_access_token_file": "` + tokenFile + `"}`, "file-token", false},
+		{"config token takes precedence", `{"genius_access_token": "config-token", "genius_access_token_file": "` + tokenFile + `"}`, "config-token", false},
+		{"missing file", `{"genius_access_token_file": "` + tokenFile + `.missing"}`, "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			writeConfig(t, tt.config)
+
+			config, err := LoadConfig()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("LoadConfig error = %v, want error %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && config.GeniusAccessToken != tt.wantToken {
+				t.Errorf("token = %q, want %q", config.GeniusAccessToken, tt.wantToken)
+			}
+		})
+	}
+}
+
+func TestLoadConfigDefaultProviders(t *testing.T) {
+	withoutKeyring(t)
+	writeConfig(t, "")
+
+	config, err := LoadConfig()
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	want := []string{providerLocal, providerGenius, providerLRCLIB}
+	if !slices.Equal(config.Providers, want) {
+		t.Errorf("providers = %q, want %q", config.Providers, want)
+	}
+	if config.needsAccessToken() {
+		t.Error("default providers need an access token")
+	}
+}
+
+func TestNeedsAccessToken(t *testing.T) {
+	tests := []struct {
+		name      string
+		providers []string
+		localDir  string
+		want      bool
+	}{
+		{"genius only", []string{providerGenius}, "", true},
+		{"genius with a fallback", []string{providerGenius, providerLRCLIB}, "", false},
+		{"local without a directory", []string{providerLocal, providerGenius}, "", true},
+		{"local with a directory", []string{providerLocal, providerGenius}, "~/lyrics", false},
+		{"no genius", []string{providerLRCLIB}, "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := Config{Providers: tt.providers, LocalLyricsDir: tt.localDir}
+			if got := config.needsAccessToken(); got != tt.want {
+				t.Errorf("needsAccessToken() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadConfigColors(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  string
+		wantErr string
+	}{
+		{"hex state color", `{"state_colors": {"error": "#FF5F5F"}}`, ""},
+		{"ANSI state color", `{"state_colors": {"loading": "202"}}`, ""},
+		{"named state color", `{"state_colors": {"error": "red"}}`, `invalid state_colors.error "red"`},
+		{"out of range state color", `{"state_colors": {"empty": "256"}}`, `invalid state_colors.empty "256"`},
+		{"unknown state", `{"state_colors": {"paused": "#FFFFFF"}}`, `invalid state "paused"`},
+		{"invalid theme color", `{"theme": {"footer": "grey"}}`, `invalid theme.footer "grey"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withoutKeyring(t)
+			writeConfig(t, tt.config)
+
+			_, err := LoadConfig()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("LoadConfig: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("LoadConfig error = %v, want one containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
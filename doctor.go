@@ -0,0 +1,194 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// doctorCheck is a single diagnostic performed by the doctor command
+type doctorCheck struct {
+	name string
+	run  func() error
+
+	// Optional checks are for features that aren't needed to show lyrics,
+	// so they only warn when they fail
+	optional bool
+}
+
+func runDoctorCommand(args []string) {
+	doctorFlags := flag.NewFlagSet("doctor", flag.ExitOnError)
+	network := doctorFlags.Bool("network", false, "Validate the Genius access token against the API")
+
+	if err := doctorFlags.Parse(args); err != nil {
+		log.Fatal(err)
+	}
+
+	// The config is checked first, as it names the player to check
+	var config Config
+	failed := !runDoctorCheck(doctorCheck{
+		name: "config is readable",
+		run: func() error {
+			var err error
+			config, err = LoadConfig()
+			return err
+		},
+	})
+
+	checks := playerChecks(config.Player)
+	checks = append(checks, doctorCheck{
+		name: "Genius access token is set",
+		run: func() error {
+			if config.GeniusAccessToken == "" {
+				return errors.New("no access token found; looked in " + tokenResolutionOrder)
+			}
+			return nil
+		},
+	})
+	if config.CacheLyrics {
+		checks = append(checks, doctorCheck{
+			name: "cache directory is writable",
+			run: func() error {
+				dir, err := getCacheDir()
+				if err != nil {
+					return err
+				}
+				return checkWritableDir(dir)
+			},
+		})
+	}
+	checks = append(checks, doctorCheck{
+		name: "clipboard tool is installed",
+		run: func() error {
+			if findClipboard() == nil {
+				return errors.New("none of wl-copy, xclip, xsel or pbcopy found; copying lyrics won't work")
+			}
+			return nil
+		},
+		optional: true,
+	})
+
+	if *network {
+		checks = append(checks, doctorCheck{
+			name: "Genius access token is valid",
+			run: func() error {
+				ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+				defer cancel()
+
+				client := newGeniusAPIClient(config)
+				_, err := client.search(ctx, "test")
+				return err
+			},
+		})
+	}
+
+	for _, check := range checks {
+		if !runDoctorCheck(check) {
+			failed = true
+		}
+	}
+
+	if failed {
+		os.Exit(1)
+	}
+}
+
+// runDoctorCheck runs the check and prints its result, returning whether it
+// passed
+func runDoctorCheck(check doctorCheck) bool {
+	if err := check.run(); err != nil && check.optional {
+		fmt.Printf("[WARN] %s: %v\n", check.name, err)
+		return true
+	} else if err != nil {
+		fmt.Printf("[FAIL] %s: %v\n", check.name, err)
+		return false
+	}
+	fmt.Printf("[PASS] %s\n", check.name)
+	return true
+}
+
+// playerChecks returns the checks that the player is installed and
+// responding. The config's default player is checked if none is named.
+func playerChecks(player string) []doctorCheck {
+	switch player {
+	case playerMPD:
+		p := NewMPDPlayer()
+		return []doctorCheck{
+			{
+				name: "MPD is responding at " + p.address,
+				run: func() error {
+					ctx, cancel := context.WithTimeout(context.Background(), playerTimeout)
+					defer cancel()
+
+					conn, _, err := p.connect(ctx)
+					if err != nil {
+						return err
+					}
+					return conn.Close()
+				},
+			},
+		}
+	case playerPlayerctl:
+		return []doctorCheck{
+			{
+				name: "playerctl is installed",
+				run: func() error {
+					_, err := exec.LookPath("playerctl")
+					return err
+				},
+			},
+			{
+				name: "playerctl finds a player",
+				run: func() error {
+					return commandCheck(exec.Command("playerctl", "status"))
+				},
+			},
+		}
+	}
+
+	return []doctorCheck{
+		{
+			name: "cmus-remote is installed",
+			run: func() error {
+				_, err := exec.LookPath("cmus-remote")
+				return err
+			},
+		},
+		{
+			name: "cmus is responding",
+			run: func() error {
+				return commandCheck(exec.Command("cmus-remote", "-Q"))
+			},
+		},
+	}
+}
+
+// checkWritableDir checks that files can be created in dir, creating it if
+// it doesn't exist
+func checkWritableDir(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(dir, ".doctor-")
+	if err != nil {
+		return err
+	}
+	f.Close()
+	return os.Remove(f.Name())
+}
+
+// commandCheck runs the command, reporting its output if it fails
+func commandCheck(cmd *exec.Cmd) error {
+	output, err := cmd.CombinedOutput()
+	if err != nil && len(bytes.TrimSpace(output)) > 0 {
+		return errors.Errorf("%v: %s", err, bytes.TrimSpace(output))
+	}
+	return err
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func TestPlayerChecks(t *testing.T) {
+	t.Setenv("MPD_HOST", "/run/mpd/socket")
+
+	tests := []struct {
+		player    string
+		wantNames []string
+	}{
+		{playerCmus, []string{"cmus-remote is installed", "cmus is responding"}},
+		{"", []string{"cmus-remote is installed", "cmus is responding"}},
+		{playerMPD, []string{"MPD is responding at /run/mpd/socket"}},
+		{playerPlayerctl, []string{"playerctl is installed", "playerctl finds a player"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.player, func(t *testing.T) {
+			checks := playerChecks(tt.player)
+			if len(checks) != len(tt.wantNames) {
+				t.Fatalf("got %d checks, want %d", len(checks), len(tt.wantNames))
+			}
+			for i, check := range checks {
+				if check.name != tt.wantNames[i] {
+					t.Errorf("check %d = %q, want %q", i, check.name, tt.wantNames[i])
+				}
+			}
+		})
+	}
+}
+
+// fakeMPD accepts connections, greeting each with the given line
+func fakeMPD(t *testing.T, greeting string) string {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			conn.Write([]byte(greeting))
+			conn.Close()
+		}
+	}()
+	return l.Addr().String()
+}
+
+func TestMPDDoctorCheck(t *testing.T) {
+	tests := []struct {
+		name     string
+		greeting string
+		wantErr  bool
+	}{
+		{"responding", "OK MPD 0.23.5\n", false},
+		{"not MPD", "SSH-2.0-OpenSSH_9.6\n", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			host, port, _ := net.SplitHostPort(fakeMPD(t, tt.greeting))
+			t.Setenv("MPD_HOST", host)
+			t.Setenv("MPD_PORT", port)
+
+			err := playerChecks(playerMPD)[0].run()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("check error = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCheckWritableDir(t *testing.T) {
+	dir := t.TempDir()
+
+	if err := checkWritableDir(filepath.Join(dir, "new", "lyrics")); err != nil {
+		t.Errorf("checkWritableDir on a missing directory: %v", err)
+	}
+	if entries, _ := os.ReadDir(filepath.Join(dir, "new", "lyrics")); len(entries) != 0 {
+		t.Errorf("left %d files behind", len(entries))
+	}
+
+	readOnly := filepath.Join(dir, "read-only")
+	if err := os.Mkdir(readOnly, 0555); err != nil {
+		t.Fatal(err)
+	}
+	if os.Getuid() != 0 {
+		if err := checkWritableDir(readOnly); err == nil {
+			t.Error("checkWritableDir on a read-only directory succeeded, want an error")
+		}
+	}
+
+	file := filepath.Join(dir, "file")
+	if err := os.WriteFile(file, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := checkWritableDir(file); err == nil {
+		t.Error("checkWritableDir on a file succeeded, want an error")
+	}
+}
+
+func TestRunDoctorCheck(t *testing.T) {
+	failing := func() error { return errors.New("missing") }
+	tests := []struct {
+		name  string
+		check doctorCheck
+		want  bool
+	}{
+		{"passing", doctorCheck{name: "ok", run: func() error { return nil }}, true},
+		{"failing", doctorCheck{name: "required", run: failing}, false},
+		{"failing optional only warns", doctorCheck{name: "optional", run: failing, optional: true}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := runDoctorCheck(tt.check); got != tt.want {
+				t.Errorf("runDoctorCheck = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
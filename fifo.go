@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// formatSongLine formats song info as the single line written to the FIFO
+func formatSongLine(msg songInfoMsg) string {
+	line := msg.title
+	if msg.artist != "" {
+		line = fmt.Sprintf("%s - %s", msg.artist, msg.title)
+	}
+	return strings.Join(strings.Fields(line), " ")
+}
+
+// ensureFIFO creates the FIFO at path if it doesn't exist. It returns an
+// error if something other than a named pipe is already there, since writing
+// to a regular file would grow it forever.
+func ensureFIFO(path string) error {
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return errors.Wrap(syscall.Mkfifo(path, 0644), "create fifo")
+	}
+	if err != nil {
+		return errors.Wrap(err, "stat fifo")
+	}
+	if info.Mode()&os.ModeNamedPipe == 0 {
+		return errors.Errorf("%s exists and is not a named pipe", path)
+	}
+	return nil
+}
+
+// fifoWriter writes lines to a FIFO, keeping it open between writes so that
+// readers such as tail -f don't see EOF after the first line
+type fifoWriter struct {
+	path string
+	f    *os.File
+}
+
+// isNoReader reports whether err means that nothing has the FIFO open for
+// reading: ENXIO when opening it, or EPIPE when writing after the last
+// reader closed it
+func isNoReader(err error) bool {
+	return errors.Is(err, syscall.ENXIO) || errors.Is(err, syscall.EPIPE)
+}
+
+// writeLine writes a line to the FIFO, opening it first if needed. The pipe
+// is opened without blocking, so a missing reader results in an error rather
+// than a hang. If the reader has gone away the pipe is reopened once, for
+// the next reader to attach.
+func (w *fifoWriter) writeLine(line string) error {
+	for attempt := 0; ; attempt++ {
+		if w.f == nil {
+			f, err := os.OpenFile(w.path, os.O_WRONLY|syscall.O_NONBLOCK, 0)
+			if err != nil {
+				return errors.Wrap(err, "open fifo")
+			}
+			w.f = f
+		}
+
+		_, err := fmt.Fprintln(w.f, line)
+		if err == nil {
+			return nil
+		}
+		w.Close()
+		if !isNoReader(err) || attempt > 0 {
+			return errors.Wrap(err, "write fifo")
+		}
+	}
+}
+
+// Close closes the FIFO if it's open
+func (w *fifoWriter) Close() {
+	if w.f != nil {
+		w.f.Close()
+		w.f = nil
+	}
+}
+
+// runFIFOMode polls the player without a TUI, writing the current song to the
+// FIFO at path whenever it changes. Status bars can then follow it with tail
+// -f. The player is checked every interval. It returns once ctx is cancelled.
+func runFIFOMode(ctx context.Context, player Player, path string, interval time.Duration) {
+	w := &fifoWriter{path: path}
+	defer w.Close()
+
+	var lastLine string
+	waiting := false
+	for {
+		msg := checkPlayerCmd(player)().(songInfoMsg)
+		line := formatSongLine(msg)
+
+		if line != lastLine {
+			// Without a reader the line is retried on the next poll, so
+			// that a reader attaching later gets the current song
+			err := w.writeLine(line)
+			switch {
+			case err == nil:
+				lastLine = line
+				waiting = false
+			case isNoReader(err):
+				if !waiting {
+					log.Printf("Waiting for a reader to open %s", path)
+					waiting = true
+				}
+			default:
+				log.Print(err)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(interval):
+		}
+	}
+}
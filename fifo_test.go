@@ -0,0 +1,114 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+func TestEnsureFIFO(t *testing.T) {
+	dir := t.TempDir()
+
+	path := filepath.Join(dir, "lyrics.fifo")
+	if err := ensureFIFO(path); err != nil {
+		t.Fatalf("ensureFIFO: %v", err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode()&os.ModeNamedPipe == 0 {
+		t.Errorf("created %v, want a named pipe", info.Mode())
+	}
+
+	// An existing pipe is reused
+	if err := ensureFIFO(path); err != nil {
+		t.Errorf("ensureFIFO on an existing pipe: %v", err)
+	}
+
+	regular := filepath.Join(dir, "regular")
+	if err := os.WriteFile(regular, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ensureFIFO(regular); err == nil {
+		t.Error("ensureFIFO on a regular file succeeded, want an error")
+	}
+}
+
+// openFIFOReader opens the FIFO for reading without waiting for a writer
+func openFIFOReader(t *testing.T, path string) (*os.File, *bufio.Reader) {
+	t.Helper()
+	f, err := os.OpenFile(path, os.O_RDONLY|syscall.O_NONBLOCK, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return f, bufio.NewReader(f)
+}
+
+// readFIFOLine reads a line written to the FIFO
+func readFIFOLine(t *testing.T, r *bufio.Reader, want string) {
+	t.Helper()
+	line, err := r.ReadString('\n')
+	if err != nil {
+		t.Fatal(err)
+	}
+	if line != want+"\n" {
+		t.Errorf("read %q, want %q", line, want+"\n")
+	}
+}
+
+func TestFIFOWriter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "lyrics.fifo")
+	if err := ensureFIFO(path); err != nil {
+		t.Fatal(err)
+	}
+	w := &fifoWriter{path: path}
+	defer w.Close()
+
+	// Without a reader the write fails instead of blocking
+	if err := w.writeLine("Artist - Title"); !isNoReader(err) {
+		t.Errorf("writeLine without a reader = %v, want a no reader error", err)
+	}
+
+	// Consecutive lines reach the same reader, as with tail -f
+	reader, r := openFIFOReader(t, path)
+	for _, line := range []string{"Artist - First", "Artist - Second"} {
+		if err := w.writeLine(line); err != nil {
+			t.Fatalf("writeLine(%q): %v", line, err)
+		}
+		readFIFOLine(t, r, line)
+	}
+
+	// The writer keeps the FIFO open, so the reader waits for the next line
+	// rather than seeing EOF
+	reader.SetReadDeadline(time.Now().Add(50 * time.Millisecond))
+	if _, err := r.ReadString('\n'); !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Errorf("read between lines = %v, want it to wait for more", err)
+	}
+	reader.SetReadDeadline(time.Time{})
+
+	// Once the reader goes away, the FIFO is reopened for the next one
+	reader.Close()
+	reader, r = openFIFOReader(t, path)
+	defer reader.Close()
+	if err := w.writeLine("Artist - Third"); err != nil {
+		t.Fatalf("writeLine after the reader changed: %v", err)
+	}
+	readFIFOLine(t, r, "Artist - Third")
+}
+
+func TestFIFOWriterDoesNotCreateFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "missing.fifo")
+	w := &fifoWriter{path: path}
+	if err := w.writeLine("Artist - Title"); err == nil {
+		t.Error("writeLine to a missing path succeeded, want an error")
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("writeLine created %s", path)
+	}
+}
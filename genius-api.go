@@ -1,51 +1,336 @@
 package main
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
+	"math/rand"
 	"net/http"
 	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"syscall"
+	"time"
+	"unicode"
 
 	"github.com/PuerkitoBio/goquery"
 	"github.com/pkg/errors"
+	"golang.org/x/net/html"
 )
 
 type SearchResponse struct {
 	Response struct {
-		Hits []struct {
-			Type   string `json:"type"`
-			Result struct {
-				ID          int64  `json:"id"`
-				Title       string `json:"title"`
-				ArtistNames string `json:"artist_names"`
-			} `json:"result"`
-		} `json:"hits"`
+		Hits []SearchHit `json:"hits"`
 	} `json:"response"`
 }
 
+// SearchHit is a result of a Genius search
+type SearchHit struct {
+	Type   string `json:"type"`
+	Result struct {
+		ID          int64  `json:"id"`
+		Title       string `json:"title"`
+		ArtistNames string `json:"artist_names"`
+	} `json:"result"`
+}
+
 type GetSongResponse struct {
 	Response struct {
 		Song struct {
-			Path string `json:"path"`
+			ID    int64  `json:"id"`
+			Path  string `json:"path"`
+			Album struct {
+				Name string `json:"name"`
+			} `json:"album"`
 		} `json:"song"`
 	} `json:"response"`
 }
 
+type ReferentsResponse struct {
+	Response struct {
+		Referents []struct {
+			Fragment    string `json:"fragment"`
+			Annotations []struct {
+				Body struct {
+					Plain string `json:"plain"`
+				} `json:"body"`
+			} `json:"annotations"`
+		} `json:"referents"`
+	} `json:"response"`
+}
+
+// Annotation is a Genius annotation explaining a fragment of the lyrics
+type Annotation struct {
+	Fragment string
+	Body     string
+}
+
+// defaultScrapeHeaders are sent with every lyrics page request. Genius
+// occasionally blocks requests that lack them.
+var defaultScrapeHeaders = map[string]string{
+	"Accept":          "text/html",
+	"Accept-Language": "en-US",
+}
+
+// Ways the album can be used when searching, see Config.AlbumSearchMode
+const (
+	albumSearchModeQuery  = "query"
+	albumSearchModeRerank = "rerank"
+	albumSearchModeOff    = "off"
+)
+
+// rerankHitLimit is how many search hits are checked for a matching album
+// in rerank mode
+const rerankHitLimit = 3
+
+// defaultRequestTimeout limits requests so that a stalled connection can't
+// leave a fetch hanging forever
+const defaultRequestTimeout = 10 * time.Second
+
+// emptyScrapeRetryDelay is how long to wait before scraping a page again
+// when its lyrics container was empty
+const emptyScrapeRetryDelay = time.Second
+
+// errNoResults is returned when the search finds no matching song
+var errNoResults = &NotFoundError{Provider: providerGenius}
+
+// errNoAccessToken is returned before any request is made when no access
+// token is configured, which Genius would otherwise reject with a 401
+var errNoAccessToken = errors.New("no Genius access token configured. " +
+	"Create an API client at https://genius.com/api-clients, generate an access token " +
+	"and set it as genius_access_token in ~/.config/lyrics/config.json. " +
+	"The token is looked for in " + tokenResolutionOrder)
+
+// errEmptyLyrics is returned when the lyrics container is present on the page
+// but holds no text, which happens when Genius serves a partial page
+var errEmptyLyrics = errors.New("lyrics container is empty")
+
+// GeniusAPIClientOptions configures a GeniusAPIClient
+type GeniusAPIClientOptions struct {
+	// ScrapeHeaders are merged over the defaults and applied when scraping
+	// the lyrics page
+	ScrapeHeaders map[string]string
+
+	// AlbumSearchMode defaults to rerank if empty
+	AlbumSearchMode string
+
+	// RetryEmptyScrape scrapes the lyrics page once more if its lyrics
+	// container was empty
+	RetryEmptyScrape bool
+
+	// SearchQueryTemplate builds the search query from the {artist},
+	// {album} and {title} placeholders. If empty, the default template is
+	// used and the album is added according to AlbumSearchMode.
+	SearchQueryTemplate string
+
+	// LyricLineFilters are removed from each scraped lyric line
+	LyricLineFilters []*regexp.Regexp
+
+	// Timeout limits each request, including reading the response.
+	// Defaults to defaultRequestTimeout if zero.
+	Timeout time.Duration
+}
+
+// ScrapeStats describes the cost of scraping a lyrics page, for debugging
+type ScrapeStats struct {
+	// HTMLSize is the size of the page in bytes
+	HTMLSize int
+
+	// ParseTime is how long goquery took to parse the page and extract the
+	// lyrics
+	ParseTime time.Duration
+}
+
 type GeniusAPIClient struct {
-	accessToken string
+	httpClient          *http.Client
+	apiBaseURL          string
+	webBaseURL          string
+	accessToken         string
+	scrapeHeaders       map[string]string
+	albumSearchMode     string
+	retryEmptyScrape    bool
+	searchQueryTemplate string
+	lyricLineFilters    []*regexp.Regexp
+
+	// Stats of the most recent successful scrape
+	statsMu         sync.Mutex
+	lastScrapeStats ScrapeStats
 }
 
-func NewGeniusAPIClient(accessToken string) *GeniusAPIClient {
+// Base URLs of the Genius API and of the website the lyrics are scraped from
+const (
+	geniusAPIBaseURL = "https://api.genius.com"
+	geniusWebBaseURL = "https://genius.com"
+)
+
+func NewGeniusAPIClient(accessToken string, opts GeniusAPIClientOptions) *GeniusAPIClient {
+	headers := make(map[string]string, len(defaultScrapeHeaders)+len(opts.ScrapeHeaders))
+	for k, v := range defaultScrapeHeaders {
+		headers[k] = v
+	}
+	for k, v := range opts.ScrapeHeaders {
+		headers[k] = v
+	}
+
+	albumSearchMode := opts.AlbumSearchMode
+	if albumSearchMode == "" {
+		albumSearchMode = albumSearchModeRerank
+	}
+
+	timeout := opts.Timeout
+	if timeout == 0 {
+		timeout = defaultRequestTimeout
+	}
+
 	c := &GeniusAPIClient{
-		accessToken: accessToken,
+		httpClient:          &http.Client{Transport: newTransport(), Timeout: timeout},
+		apiBaseURL:          geniusAPIBaseURL,
+		webBaseURL:          geniusWebBaseURL,
+		accessToken:         accessToken,
+		scrapeHeaders:       headers,
+		albumSearchMode:     albumSearchMode,
+		retryEmptyScrape:    opts.RetryEmptyScrape,
+		searchQueryTemplate: opts.SearchQueryTemplate,
+		lyricLineFilters:    opts.LyricLineFilters,
 	}
 	return c
 }
 
+// newTransport returns the HTTP transport used for Genius requests. Idle
+// connections are closed well before Genius drops them, so that reused
+// connections are rarely reset.
+func newTransport() *http.Transport {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.ForceAttemptHTTP2 = true
+	t.MaxIdleConnsPerHost = 4
+	t.IdleConnTimeout = 30 * time.Second
+	t.TLSHandshakeTimeout = 10 * time.Second
+	return t
+}
+
+// isTransientNetError reports whether err is a connection error that is
+// likely to succeed on retry, such as a reset or EOF on a reused connection
+func isTransientNetError(err error) bool {
+	return errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// Requests that are rate limited or hit a server error are retried up to
+// maxRequestAttempts times in total, waiting retryBaseDelay before the first
+// retry and twice as long before each one after
+const (
+	maxRequestAttempts = 3
+	retryBaseDelay     = 500 * time.Millisecond
+)
+
+// isRetryableStatus reports whether a response with the status code is worth
+// retrying
+func isRetryableStatus(code int) bool {
+	switch code {
+	case http.StatusTooManyRequests,
+		http.StatusInternalServerError,
+		http.StatusBadGateway,
+		http.StatusServiceUnavailable,
+		http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// retryDelay returns how long to wait before the given retry, starting at 1.
+// Up to half the delay is added as jitter, so that clients don't retry in
+// lockstep.
+func retryDelay(retry int) time.Duration {
+	delay := retryBaseDelay << (retry - 1)
+	return delay + time.Duration(rand.Int63n(int64(delay/2)+1))
+}
+
+// maxRetryAfter is the longest Retry-After that is waited for. Longer waits
+// give up instead, rather than leaving the fetch hanging.
+const maxRetryAfter = 30 * time.Second
+
+// parseRetryAfter parses a Retry-After header, which is either a number of
+// seconds or an HTTP date, into how long to wait from now
+func parseRetryAfter(header string, now time.Time) (time.Duration, bool) {
+	header = strings.TrimSpace(header)
+	if header == "" {
+		return 0, false
+	}
+
+	if seconds, err := strconv.Atoi(header); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+
+	if date, err := http.ParseTime(header); err == nil {
+		if wait := date.Sub(now); wait > 0 {
+			return wait, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
+
+// sleepContext waits for the duration, returning early with the context's
+// error if it's cancelled first
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// do sends the request, transparently retrying once on transient connection
+// errors, and with backoff when rate limited or on server errors. Requests
+// must not have a body.
+func (c *GeniusAPIClient) do(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	for attempt := 1; ; attempt++ {
+		resp, err := c.httpClient.Do(req)
+		if err != nil && isTransientNetError(err) && ctx.Err() == nil {
+			resp, err = c.httpClient.Do(req.Clone(ctx))
+		}
+		if err != nil || !isRetryableStatus(resp.StatusCode) || attempt == maxRequestAttempts {
+			return resp, err
+		}
+
+		// Wait as long as a rate limited response asks for, if it says
+		delay := retryDelay(attempt)
+		if resp.StatusCode == http.StatusTooManyRequests {
+			if wait, ok := parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()); ok {
+				if wait > maxRetryAfter {
+					return resp, nil
+				}
+				delay = wait
+			}
+		}
+
+		// Drain the body so that the connection can be reused
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+
+		if err := sleepContext(ctx, delay); err != nil {
+			return nil, err
+		}
+		req = req.Clone(ctx)
+	}
+}
+
 func (c *GeniusAPIClient) search(ctx context.Context, query string) (SearchResponse, error) {
-	baseURL := "https://api.genius.com/search"
+	baseURL := c.apiBaseURL + "/search"
 
 	// Create URL with properly encoded query parameter
 	params := url.Values{}
@@ -62,7 +347,7 @@ func (c *GeniusAPIClient) search(ctx context.Context, query string) (SearchRespo
 	req.Header.Set("Authorization", "Bearer "+c.accessToken)
 
 	// Send request
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
 		return SearchResponse{}, errors.Wrap(err, "send request")
 	}
@@ -83,7 +368,7 @@ func (c *GeniusAPIClient) search(ctx context.Context, query string) (SearchRespo
 }
 
 func (c *GeniusAPIClient) getSong(ctx context.Context, id int64) (GetSongResponse, error) {
-	requestURL := fmt.Sprintf("https://api.genius.com/songs/%d", id)
+	requestURL := fmt.Sprintf("%s/songs/%d", c.apiBaseURL, id)
 
 	// Create request with context
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, requestURL, nil)
@@ -95,7 +380,7 @@ func (c *GeniusAPIClient) getSong(ctx context.Context, id int64) (GetSongRespons
 	req.Header.Set("Authorization", "Bearer "+c.accessToken)
 
 	// Send request
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
 		return GetSongResponse{}, errors.Wrap(err, "send request")
 	}
@@ -117,7 +402,7 @@ func (c *GeniusAPIClient) getSong(ctx context.Context, id int64) (GetSongRespons
 
 func (c *GeniusAPIClient) getLyrics(ctx context.Context, path string) (string, error) {
 	// Construct the full URL
-	fullURL := fmt.Sprintf("https://genius.com%s", path)
+	fullURL := c.webBaseURL + path
 
 	// Create request with context
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fullURL, nil)
@@ -125,8 +410,13 @@ func (c *GeniusAPIClient) getLyrics(ctx context.Context, path string) (string, e
 		return "", errors.Wrap(err, "create request")
 	}
 
+	// Set scrape headers
+	for k, v := range c.scrapeHeaders {
+		req.Header.Set(k, v)
+	}
+
 	// Send request
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
 		return "", errors.Wrap(err, "send request")
 	}
@@ -137,65 +427,558 @@ func (c *GeniusAPIClient) getLyrics(ctx context.Context, path string) (string, e
 		return "", fmt.Errorf("unexpected status code: %d", resp.StatusCode)
 	}
 
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", errors.Wrap(err, "read response")
+	}
+
 	// Parse HTML with goquery
-	doc, err := goquery.NewDocumentFromReader(resp.Body)
+	parseStart := time.Now()
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(body))
 	if err != nil {
 		return "", errors.Wrap(err, "parse HTML")
 	}
 
-	// Find the lyrics container by data attribute and class prefix
+	// Find the lyrics container by data attribute and extract its text
+	// directly from the parsed page, turning line breaks into newlines
 	var lyricsText strings.Builder
-	doc.Find("[data-lyrics-container=\"true\"]").Each(func(i int, s *goquery.Selection) {
+	containers := doc.Find("[data-lyrics-container=\"true\"]")
+	containers.Each(func(i int, s *goquery.Selection) {
 		// Remove elements that should be excluded from selection
 		s.Find("[data-exclude-from-selection=\"true\"]").Remove()
 
-		// Get the HTML content and append to our builder
-		html, err := s.Html()
-		if err != nil {
-			return
+		// Containers are split mid-song, so the last line of one mustn't
+		// run into the first of the next
+		if i > 0 {
+			lyricsText.WriteString("\n")
+		}
+
+		for _, node := range s.Nodes {
+			writeNodeText(&lyricsText, node)
 		}
-		lyricsText.WriteString(html)
 	})
 
 	if lyricsText.Len() == 0 {
+		if containers.Length() > 0 {
+			return "", errEmptyLyrics
+		}
+		if isConsentPage(doc) {
+			return "", errors.New("genius served a consent or region page instead of lyrics; " +
+				"try setting a Cookie or Accept-Language header via scrape_headers in config.json, or use a VPN")
+		}
 		return "", errors.New("no lyrics found on page")
 	}
 
-	// Replace HTML line breaks with actual newlines
-	lyrics := strings.ReplaceAll(lyricsText.String(), "<br/>", "\n")
-	lyrics = strings.ReplaceAll(lyrics, "<br>", "\n")
+	c.statsMu.Lock()
+	c.lastScrapeStats = ScrapeStats{HTMLSize: len(body), ParseTime: time.Since(parseStart)}
+	c.statsMu.Unlock()
 
-	// Create a new document to parse the lyrics HTML and extract just the text
-	lyricDoc, err := goquery.NewDocumentFromReader(strings.NewReader("<div>" + lyrics + "</div>"))
-	if err != nil {
-		return "", errors.Wrap(err, "parse lyrics HTML")
+	cleanLyrics := removeRecommendations(lyricsText.String())
+	cleanLyrics = formatSectionHeaders(cleanLyrics)
+	cleanLyrics = strings.TrimSpace(applyLineFilters(cleanLyrics, c.lyricLineFilters))
+	if cleanLyrics == "" {
+		return "", errEmptyLyrics
 	}
 
-	// Extract text content
-	cleanLyrics := strings.TrimSpace(lyricDoc.Text())
-
 	return cleanLyrics, nil
 }
 
-func (c *GeniusAPIClient) GetLyrics(ctx context.Context, artist string, title string) (string, error) {
-	searchResp, err := c.search(ctx, fmt.Sprintf("%s %s", artist, title))
+// writeNodeText writes the text of an HTML node and its descendants, with a
+// newline for each line break
+func writeNodeText(b *strings.Builder, node *html.Node) {
+	switch {
+	case node.Type == html.TextNode:
+		b.WriteString(node.Data)
+	case node.Type == html.ElementNode && node.Data == "br":
+		b.WriteString("\n")
+	}
+	for child := node.FirstChild; child != nil; child = child.NextSibling {
+		writeNodeText(b, child)
+	}
+}
+
+// applyLineFilters removes the parts of each line matching any of the
+// filters. Lines left empty by the filters are dropped, while lines that were
+// already blank are kept.
+func applyLineFilters(lyrics string, filters []*regexp.Regexp) string {
+	if len(filters) == 0 {
+		return lyrics
+	}
+
+	lines := strings.Split(lyrics, "\n")
+	kept := make([]string, 0, len(lines))
+	for _, line := range lines {
+		filtered := line
+		for _, filter := range filters {
+			filtered = filter.ReplaceAllString(filtered, "")
+		}
+		if strings.TrimSpace(filtered) == "" && strings.TrimSpace(line) != "" {
+			continue
+		}
+		kept = append(kept, filtered)
+	}
+	return strings.Join(kept, "\n")
+}
+
+// recommendationPhrases are snippets that Genius sometimes leaks into the
+// lyrics body from its recommendation widgets
+var recommendationPhrases = []string{
+	"You might also like",
+}
+
+// removeRecommendations drops lines consisting only of a recommendation
+// phrase. The phrase is also removed when it's glued directly onto the end of
+// a lyric line, which happens when the widget has no surrounding line break.
+// Lyric lines that merely contain the phrase are left alone.
+func removeRecommendations(lyrics string) string {
+	lines := strings.Split(lyrics, "\n")
+	kept := make([]string, 0, len(lines))
+	for _, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		drop := false
+		for _, phrase := range recommendationPhrases {
+			if trimmed == phrase {
+				drop = true
+				break
+			}
+
+			// Only strip a suffix that directly follows another word
+			if before, ok := strings.CutSuffix(trimmed, phrase); ok && before != "" && !strings.HasSuffix(before, " ") {
+				line = before
+			}
+		}
+		if !drop {
+			kept = append(kept, line)
+		}
+	}
+	return strings.Join(kept, "\n")
+}
+
+// sectionHeaderPattern matches a section header such as "[Chorus]" or
+// "[Verse 1: Artist]" on a line of its own
+var sectionHeaderPattern = regexp.MustCompile(`^\[[^\[\]]+\]$`)
+
+// gluedSectionHeaderPattern matches a section header glued onto the end of a
+// lyric line, which happens when Genius omits the line break before it
+var gluedSectionHeaderPattern = regexp.MustCompile(`(\S)(\[[^\[\]]+\])$`)
+
+// formatSectionHeaders puts each section header on its own line, with a
+// blank line before it so that sections are set apart
+func formatSectionHeaders(lyrics string) string {
+	var out []string
+	for _, line := range strings.Split(lyrics, "\n") {
+		pieces := []string{line}
+		if m := gluedSectionHeaderPattern.FindStringSubmatchIndex(strings.TrimRight(line, " ")); m != nil {
+			pieces = []string{line[:m[3]], line[m[4]:m[5]]}
+		}
+
+		for _, piece := range pieces {
+			if sectionHeaderPattern.MatchString(strings.TrimSpace(piece)) && len(out) > 0 && strings.TrimSpace(out[len(out)-1]) != "" {
+				out = append(out, "")
+			}
+			out = append(out, piece)
+		}
+	}
+	return strings.Join(out, "\n")
+}
+
+// removeSectionHeaders drops section header lines, for readers who prefer
+// the lyrics as a clean block. Blank lines left doubled up are collapsed.
+func removeSectionHeaders(lyrics string) string {
+	var out []string
+	for _, line := range strings.Split(lyrics, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if sectionHeaderPattern.MatchString(trimmed) {
+			continue
+		}
+		if trimmed == "" && (len(out) == 0 || strings.TrimSpace(out[len(out)-1]) == "") {
+			continue
+		}
+		out = append(out, line)
+	}
+	return strings.TrimSpace(strings.Join(out, "\n"))
+}
+
+// consentPageSelectors match markup found on Genius' consent and region
+// interstitial pages
+var consentPageSelectors = []string{
+	"form[action*=\"consent\"]",
+	"[id*=\"consent\"]",
+	"[class*=\"consent\"]",
+	"iframe[src*=\"consent\"]",
+}
+
+// isConsentPage reports whether the page looks like a consent or region
+// interstitial rather than a lyrics page
+func isConsentPage(doc *goquery.Document) bool {
+	for _, selector := range consentPageSelectors {
+		if doc.Find(selector).Length() > 0 {
+			return true
+		}
+	}
+
+	// Fall back to checking the page title for known phrases
+	title := strings.ToLower(doc.Find("title").Text())
+	return strings.Contains(title, "consent") || strings.Contains(title, "not available in your region")
+}
+
+// stripPunctuation normalizes a search query by converting curly quotes to
+// straight ones, dropping periods (so "W.A.P." becomes "WAP") and replacing
+// other punctuation and symbols with spaces
+func stripPunctuation(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r == '\u2018' || r == '\u2019':
+			b.WriteRune('\'')
+		case r == '\'':
+			b.WriteRune(r)
+		case r == '.' || r == '\u201C' || r == '\u201D' || r == '"':
+			// Drop entirely
+		case unicode.IsPunct(r) || unicode.IsSymbol(r):
+			b.WriteRune(' ')
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return strings.Join(strings.Fields(b.String()), " ")
+}
+
+// getSongMatchingAlbum returns the first of the top search hits whose album
+// matches the given album, falling back to the first hit
+func (c *GeniusAPIClient) getSongMatchingAlbum(ctx context.Context, hits []SearchHit, album string) (GetSongResponse, error) {
+	var first GetSongResponse
+	for i, hit := range hits {
+		if i >= rerankHitLimit {
+			break
+		}
+
+		songResp, err := c.getSong(ctx, hit.Result.ID)
+		if err != nil {
+			return GetSongResponse{}, err
+		}
+		if i == 0 {
+			first = songResp
+		}
+
+		hitAlbum := strings.ToLower(songResp.Response.Song.Album.Name)
+		if hitAlbum != "" && (strings.Contains(hitAlbum, strings.ToLower(album)) || strings.Contains(strings.ToLower(album), hitAlbum)) {
+			return songResp, nil
+		}
+	}
+	return first, nil
+}
+
+// defaultSearchQueryTemplate is the search query used when no template is
+// configured
+const defaultSearchQueryTemplate = "{artist} {title}"
+
+// expandSearchQueryTemplate fills in the placeholders of a search query
+// template. Whitespace is collapsed so that empty fields don't leave gaps.
+func expandSearchQueryTemplate(template, artist, album, title string) string {
+	query := strings.NewReplacer(
+		"{artist}", artist,
+		"{album}", album,
+		"{title}", title,
+	).Replace(template)
+	return strings.Join(strings.Fields(query), " ")
+}
+
+// Titles longer than longTitleWords are also searched for by their first
+// shortTitleWords words, as long titles such as those of live bootlegs
+// overwhelm the search
+const (
+	longTitleWords  = 8
+	shortTitleWords = 4
+)
+
+// shortenTitle returns the start of a long title, dropping descriptors such
+// as " - Live at Venue, City, Date" or "(Remastered)". It returns the title
+// unchanged if it isn't long.
+func shortenTitle(title string) string {
+	if len(strings.Fields(title)) <= longTitleWords {
+		return title
+	}
+
+	for _, sep := range []string{" - ", " – ", " (", " [", ", ", ": "} {
+		if i := strings.Index(title, sep); i > 0 {
+			title = title[:i]
+		}
+	}
+	words := strings.Fields(title)
+	if len(words) > shortTitleWords {
+		words = words[:shortTitleWords]
+	}
+	return strings.Join(words, " ")
+}
+
+// titleMatches reports whether most of the words of title appear in the
+// title of a search hit, to guard against unrelated hits for shortened titles
+func titleMatches(hitTitle, title string) bool {
+	hitWords := make(map[string]bool)
+	for _, word := range filenameTokens(hitTitle) {
+		hitWords[word] = true
+	}
+
+	words := filenameTokens(title)
+	matched := 0
+	for _, word := range words {
+		if hitWords[word] {
+			matched++
+		}
+	}
+	return len(words) > 0 && matched*2 >= len(words)
+}
+
+// hitMatchThreshold is the minimum score for a search hit to be considered
+// the song, see scoreHit
+const hitMatchThreshold = 0.5
+
+// levenshtein returns the number of single rune edits needed to turn a into b
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		cur := make([]int, len(rb)+1)
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev = cur
+	}
+	return prev[len(rb)]
+}
+
+// similarity scores how alike two names are, from 0 to 1, by their edit
+// distance once case, spaces and punctuation are ignored
+func similarity(a, b string) float64 {
+	a, b = normalizeFilename(a), normalizeFilename(b)
+	longest := max(len([]rune(a)), len([]rune(b)))
+	if longest == 0 {
+		return 0
+	}
+	return 1 - float64(levenshtein(a, b))/float64(longest)
+}
+
+// scoreHit scores how well a search hit matches the artist and title, from 0
+// to 1. The title must be similar for a hit to score well, while a differing
+// artist only halves the score, since tags may spell it differently. Hits
+// credited to several artists match if one of them is the artist, and hits
+// that aren't songs score lower. Without an artist, only the title is scored.
+func scoreHit(hit SearchHit, artist, title string) float64 {
+	score := similarity(hit.Result.Title, title)
+	if normalizeFilename(artist) != "" {
+		artistScore := similarity(hit.Result.ArtistNames, artist)
+		if a, hitArtist := normalizeFilename(artist), normalizeFilename(hit.Result.ArtistNames); strings.Contains(hitArtist, a) {
+			artistScore = 1
+		}
+		score *= 0.5 + 0.5*artistScore
+	}
+	if hit.Type != "song" {
+		score /= 2
+	}
+	return score
+}
+
+// rankHits returns the hits that score at least hitMatchThreshold against
+// the artist and title, best first
+func rankHits(hits []SearchHit, artist, title string) []SearchHit {
+	scores := make(map[int64]float64, len(hits))
+	var ranked []SearchHit
+	for _, hit := range hits {
+		score := scoreHit(hit, artist, title)
+		if score >= hitMatchThreshold {
+			scores[hit.Result.ID] = score
+			ranked = append(ranked, hit)
+		}
+	}
+
+	sort.SliceStable(ranked, func(i, j int) bool {
+		return scores[ranked[i].Result.ID] > scores[ranked[j].Result.ID]
+	})
+	return ranked
+}
+
+// matchHits returns the hits that match the song, best first. The top hit is
+// often a remix, a cover or another artist's song, so the hits are ranked by
+// how closely they match. Free-form queries have no title to match, so
+// Genius's order is kept.
+func matchHits(hits []SearchHit, artist, title string) []SearchHit {
+	if title == "" {
+		return hits
+	}
+	return rankHits(hits, artist, title)
+}
+
+// findSong searches Genius for the song and returns the best hit. The title
+// and album are searched for without descriptors such as featured artists or
+// remaster notes.
+func (c *GeniusAPIClient) findSong(ctx context.Context, artist string, album string, title string) (GetSongResponse, error) {
+	if c.accessToken == "" {
+		return GetSongResponse{}, errNoAccessToken
+	}
+
+	title = cleanTitle(title)
+	album = cleanTitle(album)
+
+	template := c.searchQueryTemplate
+	if template == "" {
+		template = defaultSearchQueryTemplate
+		if album != "" && c.albumSearchMode == albumSearchModeQuery {
+			template = "{artist} {album} {title}"
+		}
+	}
+	query := expandSearchQueryTemplate(template, artist, album, title)
+
+	searchResp, err := c.search(ctx, query)
 	if err != nil {
-		return "", errors.Wrap(err, "search genius api")
+		return GetSongResponse{}, errors.Wrap(err, "search genius api")
+	}
+	found := len(searchResp.Response.Hits) > 0
+	hits := matchHits(searchResp.Response.Hits, artist, title)
+
+	// Stylized punctuation can trip up the search, so retry without it
+	// when no hit matches confidently
+	if len(hits) == 0 {
+		if normalized := stripPunctuation(query); normalized != query {
+			searchResp, err = c.search(ctx, normalized)
+			if err != nil {
+				return GetSongResponse{}, errors.Wrap(err, "search genius api")
+			}
+			found = found || len(searchResp.Response.Hits) > 0
+			hits = matchHits(searchResp.Response.Hits, artist, title)
+		}
 	}
 
-	if len(searchResp.Response.Hits) == 0 {
-		return "", errors.New("no results")
+	// Long titles are retried by their first few words when no hit matches
+	// confidently, as long as the best hit looks like the same song
+	if len(hits) == 0 {
+		if short := shortenTitle(title); short != title {
+			searchResp, err = c.search(ctx, stripPunctuation(expandSearchQueryTemplate(template, artist, album, short)))
+			if err != nil {
+				return GetSongResponse{}, errors.Wrap(err, "search genius api")
+			}
+			found = found || len(searchResp.Response.Hits) > 0
+			hits = matchHits(searchResp.Response.Hits, artist, short)
+			if len(hits) > 0 && !titleMatches(hits[0].Result.Title, short) {
+				hits = nil
+			}
+		}
 	}
 
-	songResp, err := c.getSong(ctx, searchResp.Response.Hits[0].Result.ID)
+	if !found {
+		return GetSongResponse{}, errNoResults
+	}
+	if len(hits) == 0 {
+		return GetSongResponse{}, errors.Wrap(errNoResults, "no confident match")
+	}
+
+	var songResp GetSongResponse
+	if album != "" && c.albumSearchMode == albumSearchModeRerank {
+		songResp, err = c.getSongMatchingAlbum(ctx, hits, album)
+	} else {
+		songResp, err = c.getSong(ctx, hits[0].Result.ID)
+	}
 	if err != nil {
-		return "", errors.Wrap(err, "get song from genius api")
+		return GetSongResponse{}, errors.Wrap(err, "get song from genius api")
+	}
+
+	return songResp, nil
+}
+
+func (c *GeniusAPIClient) GetLyrics(ctx context.Context, artist string, album string, title string) (string, error) {
+	songResp, err := c.findSong(ctx, artist, album, title)
+	if err != nil {
+		return "", err
 	}
 
 	lyrics, err := c.getLyrics(ctx, songResp.Response.Song.Path)
+
+	// Genius occasionally serves a partial page that fills in on retry
+	if errors.Is(err, errEmptyLyrics) && c.retryEmptyScrape {
+		if err := sleepContext(ctx, emptyScrapeRetryDelay); err != nil {
+			return "", err
+		}
+		lyrics, err = c.getLyrics(ctx, songResp.Response.Song.Path)
+	}
+
 	if err != nil {
 		return "", errors.Wrap(err, "scrape lyrics from genius webpage")
 	}
 
 	return lyrics, nil
 }
+
+// LastScrapeStats returns the stats of the most recent successful scrape
+func (c *GeniusAPIClient) LastScrapeStats() ScrapeStats {
+	c.statsMu.Lock()
+	defer c.statsMu.Unlock()
+	return c.lastScrapeStats
+}
+
+func (c *GeniusAPIClient) getReferents(ctx context.Context, songID int64) (ReferentsResponse, error) {
+	requestURL := fmt.Sprintf("%s/referents?song_id=%d&text_format=plain&per_page=50", c.apiBaseURL, songID)
+
+	// Create request with context
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, requestURL, nil)
+	if err != nil {
+		return ReferentsResponse{}, errors.Wrap(err, "create request")
+	}
+
+	// Set authorization header
+	req.Header.Set("Authorization", "Bearer "+c.accessToken)
+
+	// Send request
+	resp, err := c.do(req)
+	if err != nil {
+		return ReferentsResponse{}, errors.Wrap(err, "send request")
+	}
+	defer resp.Body.Close()
+
+	// Check status code
+	if resp.StatusCode != http.StatusOK {
+		return ReferentsResponse{}, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+
+	// Decode response
+	var referentsResp ReferentsResponse
+	if err := json.NewDecoder(resp.Body).Decode(&referentsResp); err != nil {
+		return ReferentsResponse{}, errors.Wrap(err, "decode response")
+	}
+
+	return referentsResp, nil
+}
+
+// GetAnnotations returns the annotations for the song
+func (c *GeniusAPIClient) GetAnnotations(ctx context.Context, artist string, album string, title string) ([]Annotation, error) {
+	songResp, err := c.findSong(ctx, artist, album, title)
+	if err != nil {
+		return nil, err
+	}
+
+	referentsResp, err := c.getReferents(ctx, songResp.Response.Song.ID)
+	if err != nil {
+		return nil, errors.Wrap(err, "get referents from genius api")
+	}
+
+	var annotations []Annotation
+	for _, referent := range referentsResp.Response.Referents {
+		if len(referent.Annotations) == 0 {
+			continue
+		}
+		annotations = append(annotations, Annotation{
+			Fragment: referent.Fragment,
+			Body:     strings.TrimSpace(referent.Annotations[0].Body.Plain),
+		})
+	}
+
+	return annotations, nil
+}
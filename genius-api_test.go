@@ -0,0 +1,847 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/pkg/errors"
+)
+
+// fakeGenius serves search results by query, and a song page for every song
+// ID. Queries without results get no hits.
+type fakeGenius struct {
+	results map[string][]SearchHit
+
+	mu      sync.Mutex
+	queries []string
+}
+
+func (f *fakeGenius) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.URL.Path == "/search":
+		query := r.URL.Query().Get("q")
+		f.mu.Lock()
+		f.queries = append(f.queries, query)
+		f.mu.Unlock()
+
+		var resp SearchResponse
+		resp.Response.Hits = f.results[query]
+		json.NewEncoder(w).Encode(resp)
+	case strings.HasPrefix(r.URL.Path, "/songs/"):
+		var resp GetSongResponse
+		fmt.Sscan(strings.TrimPrefix(r.URL.Path, "/songs/"), &resp.Response.Song.ID)
+		resp.Response.Song.Path = fmt.Sprintf("/song-%d", resp.Response.Song.ID)
+		json.NewEncoder(w).Encode(resp)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// searchedQueries returns the queries searched for so far
+func (f *fakeGenius) searchedQueries() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.queries...)
+}
+
+// newTestGeniusClient returns a client that sends its requests to the
+// handler instead of Genius
+func newTestGeniusClient(t *testing.T, handler http.Handler) *GeniusAPIClient {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	c := NewGeniusAPIClient("token", GeniusAPIClientOptions{AlbumSearchMode: albumSearchModeOff})
+	c.apiBaseURL = server.URL
+	c.webBaseURL = server.URL
+	return c
+}
+
+// hit returns a search hit for a song
+func hit(id int64, artist, title string) SearchHit {
+	var h SearchHit
+	h.Type = "song"
+	h.Result.ID = id
+	h.Result.ArtistNames = artist
+	h.Result.Title = title
+	return h
+}
+
+func TestStripPunctuation(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"Cardi B W.A.P.", "Cardi B WAP"},
+		{"Queen Don’t Stop Me Now", "Queen Don't Stop Me Now"},
+		{"Frank Ocean “Thinkin Bout You”", "Frank Ocean Thinkin Bout You"},
+		{"P!nk So What", "P nk So What"},
+		{"AC/DC T.N.T.", "AC DC TNT"},
+		{"Daft Punk One More Time", "Daft Punk One More Time"},
+	}
+
+	for _, tt := range tests {
+		if got := stripPunctuation(tt.in); got != tt.want {
+			t.Errorf("stripPunctuation(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestFindSongRetriesWithoutPunctuation(t *testing.T) {
+	tests := []struct {
+		name        string
+		artist      string
+		title       string
+		results     map[string][]SearchHit
+		wantID      int64
+		wantQueries []string
+	}{
+		{
+			name:   "periods dropped after unrelated hits",
+			artist: "Cardi B",
+			title:  "W.A.P.",
+			results: map[string][]SearchHit{
+				"Cardi B W.A.P.": {hit(1, "Cardi B", "Bodak Yellow")},
+				"Cardi B WAP":    {hit(2, "Cardi B", "WAP")},
+			},
+			wantID:      2,
+			wantQueries: []string{"Cardi B W.A.P.", "Cardi B WAP"},
+		},
+		{
+			name:   "curly quotes straightened after no hits",
+			artist: "Queen",
+			title:  "Don’t Stop Me Now",
+			results: map[string][]SearchHit{
+				"Queen Don't Stop Me Now": {hit(3, "Queen", "Don't Stop Me Now")},
+			},
+			wantID:      3,
+			wantQueries: []string{"Queen Don’t Stop Me Now", "Queen Don't Stop Me Now"},
+		},
+		{
+			name:   "original query kept when it matches",
+			artist: "P!nk",
+			title:  "So What",
+			results: map[string][]SearchHit{
+				"P!nk So What": {hit(4, "P!nk", "So What")},
+			},
+			wantID:      4,
+			wantQueries: []string{"P!nk So What"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			genius := &fakeGenius{results: tt.results}
+			c := newTestGeniusClient(t, genius)
+
+			song, err := c.findSong(t.Context(), tt.artist, "", tt.title)
+			if err != nil {
+				t.Fatalf("findSong: %v", err)
+			}
+			if got := song.Response.Song.ID; got != tt.wantID {
+				t.Errorf("found song %d, want %d", got, tt.wantID)
+			}
+			if got := genius.searchedQueries(); strings.Join(got, "|") != strings.Join(tt.wantQueries, "|") {
+				t.Errorf("searched for %q, want %q", got, tt.wantQueries)
+			}
+		})
+	}
+}
+
+func TestRankHits(t *testing.T) {
+	// Near misses for "Radiohead - Creep". The acoustic version is too far
+	// from the title to count as a match.
+	nearMisses := []SearchHit{
+		hit(1, "Scala & Kolacny Brothers", "Creep"),
+		hit(2, "Radiohead", "Creep (Acoustic)"),
+		hit(3, "Radiohead", "Creep"),
+		hit(4, "TLC", "Creep"),
+		hit(5, "Radiohead", "Karma Police"),
+	}
+	album := hit(6, "Radiohead", "Creep")
+	album.Type = "album"
+
+	tests := []struct {
+		name    string
+		hits    []SearchHit
+		artist  string
+		title   string
+		wantIDs []int64
+	}{
+		{
+			name:    "exact match ranked first",
+			hits:    nearMisses,
+			artist:  "Radiohead",
+			title:   "Creep",
+			wantIDs: []int64{3, 1, 4},
+		},
+		{
+			name:    "songs preferred over other hits",
+			hits:    []SearchHit{album, hit(7, "Radiohead", "Creep")},
+			artist:  "Radiohead",
+			title:   "Creep",
+			wantIDs: []int64{7, 6},
+		},
+		{
+			name:    "featured artists match",
+			hits:    []SearchHit{hit(8, "Calvin Harris", "This Is What You Came For"), hit(9, "Calvin Harris & Rihanna", "This Is What You Came For")},
+			artist:  "Rihanna",
+			title:   "This Is What You Came For",
+			wantIDs: []int64{9, 8},
+		},
+		{
+			name:    "no confident match",
+			hits:    []SearchHit{hit(10, "Radiohead", "Karma Police"), hit(11, "Muse", "Uprising")},
+			artist:  "Radiohead",
+			title:   "Creep",
+			wantIDs: nil,
+		},
+		{
+			name:    "title only",
+			hits:    []SearchHit{hit(12, "Various Artists", "Jurassic Park"), hit(13, "John Williams", "Theme from Jurassic Park (Main Title)")},
+			artist:  "",
+			title:   "Theme from Jurassic Park",
+			wantIDs: []int64{13, 12},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []int64
+			for _, h := range rankHits(tt.hits, tt.artist, tt.title) {
+				got = append(got, h.Result.ID)
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.wantIDs) {
+				t.Errorf("rankHits = %v, want %v", got, tt.wantIDs)
+			}
+		})
+	}
+}
+
+func TestScoreHitWithoutArtist(t *testing.T) {
+	h := hit(1, "John Williams", "Theme from Jurassic Park (Main Title)")
+	if score := scoreHit(h, "", "Theme from Jurassic Park"); score < hitMatchThreshold {
+		t.Errorf("scoreHit without an artist = %.2f, want at least %.2f", score, hitMatchThreshold)
+	}
+	if score := scoreHit(h, "", "Theme from Jurassic Park (Main Title)"); score != 1 {
+		t.Errorf("scoreHit of an exact title without an artist = %.2f, want 1", score)
+	}
+}
+
+func TestFindSongRetriesShortenedTitle(t *testing.T) {
+	title := "Hey Jude - Live at the Hollywood Bowl, Los Angeles, 1965"
+	tests := []struct {
+		name    string
+		results map[string][]SearchHit
+		wantID  int64
+		wantErr bool
+	}{
+		{
+			name: "near misses for the full title",
+			results: map[string][]SearchHit{
+				"The Beatles Hey Jude - Live at the Hollywood Bowl, Los Angeles, 1965": {hit(1, "The Beatles", "Live at the Hollywood Bowl (Liner Notes)")},
+				"The Beatles Hey Jude": {hit(2, "The Beatles", "Hey Jude")},
+			},
+			wantID: 2,
+		},
+		{
+			name: "unrelated hits for the shortened title",
+			results: map[string][]SearchHit{
+				"The Beatles Hey Jude": {hit(3, "The Beatles", "Let It Be")},
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestGeniusClient(t, &fakeGenius{results: tt.results})
+			song, err := c.findSong(t.Context(), "The Beatles", "", title)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("findSong found song %d, want an error", song.Response.Song.ID)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("findSong: %v", err)
+			}
+			if got := song.Response.Song.ID; got != tt.wantID {
+				t.Errorf("found song %d, want %d", got, tt.wantID)
+			}
+		})
+	}
+}
+
+func TestRequestTimeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+		}
+	}))
+	defer server.Close()
+
+	c := NewGeniusAPIClient("token", GeniusAPIClientOptions{Timeout: 50 * time.Millisecond})
+	c.apiBaseURL = server.URL
+
+	start := time.Now()
+	_, err := c.search(t.Context(), "Artist Title")
+	if err == nil {
+		t.Fatal("search succeeded against a stalled server, want a timeout")
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("search took %v, want it to give up after the timeout", elapsed)
+	}
+}
+
+func TestDefaultRequestTimeout(t *testing.T) {
+	c := NewGeniusAPIClient("token", GeniusAPIClientOptions{})
+	if c.httpClient.Timeout != defaultRequestTimeout {
+		t.Errorf("timeout = %v, want %v", c.httpClient.Timeout, defaultRequestTimeout)
+	}
+	if c.httpClient == http.DefaultClient {
+		t.Error("client uses http.DefaultClient")
+	}
+}
+
+// flakyServer fails the first requests with the given status codes and
+// headers, then serves search results
+type flakyServer struct {
+	failures []int
+	header   http.Header
+
+	mu       sync.Mutex
+	requests int
+}
+
+func (f *flakyServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	f.requests++
+	n := f.requests
+	f.mu.Unlock()
+
+	if n <= len(f.failures) {
+		for key, values := range f.header {
+			w.Header()[key] = values
+		}
+		w.WriteHeader(f.failures[n-1])
+		return
+	}
+	json.NewEncoder(w).Encode(SearchResponse{})
+}
+
+// requestCount returns the number of requests received so far
+func (f *flakyServer) requestCount() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.requests
+}
+
+func TestRetryableStatus(t *testing.T) {
+	tests := []struct {
+		code int
+		want bool
+	}{
+		{http.StatusOK, false},
+		{http.StatusNotFound, false},
+		{http.StatusUnauthorized, false},
+		{http.StatusTooManyRequests, true},
+		{http.StatusInternalServerError, true},
+		{http.StatusBadGateway, true},
+		{http.StatusServiceUnavailable, true},
+		{http.StatusGatewayTimeout, true},
+	}
+	for _, tt := range tests {
+		if got := isRetryableStatus(tt.code); got != tt.want {
+			t.Errorf("isRetryableStatus(%d) = %v, want %v", tt.code, got, tt.want)
+		}
+	}
+}
+
+func TestRetryDelayBacksOff(t *testing.T) {
+	for retry := 1; retry <= 3; retry++ {
+		base := retryBaseDelay << (retry - 1)
+		if delay := retryDelay(retry); delay < base || delay > base+base/2 {
+			t.Errorf("retryDelay(%d) = %v, want between %v and %v", retry, delay, base, base+base/2)
+		}
+	}
+}
+
+func TestRequestRetries(t *testing.T) {
+	tests := []struct {
+		name         string
+		failures     []int
+		wantErr      bool
+		wantRequests int
+	}{
+		{"rate limited twice then ok", []int{429, 429}, false, 3},
+		{"server error then ok", []int{503}, false, 2},
+		{"gives up after the last attempt", []int{502, 502, 502}, true, maxRequestAttempts},
+		{"client errors aren't retried", []int{404}, true, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			server := &flakyServer{failures: tt.failures}
+			c := newTestGeniusClient(t, server)
+
+			_, err := c.search(t.Context(), "Artist Title")
+			if (err != nil) != tt.wantErr {
+				t.Errorf("search error = %v, want error %v", err, tt.wantErr)
+			}
+			if n := server.requestCount(); n != tt.wantRequests {
+				t.Errorf("made %d requests, want %d", n, tt.wantRequests)
+			}
+		})
+	}
+}
+
+func TestRequestRetryCancelled(t *testing.T) {
+	c := newTestGeniusClient(t, &flakyServer{failures: []int{503, 503}})
+
+	ctx, cancel := context.WithCancel(t.Context())
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	start := time.Now()
+	_, err := c.search(ctx, "Artist Title")
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("search error = %v, want context.Canceled", err)
+	}
+	if elapsed := time.Since(start); elapsed >= retryBaseDelay {
+		t.Errorf("search took %v, want it to stop waiting when cancelled", elapsed)
+	}
+}
+
+// droppingServer closes the connection without a response for the first
+// drops requests, as Genius does when it resets a reused connection
+type droppingServer struct {
+	drops int
+
+	mu       sync.Mutex
+	requests int
+}
+
+func (d *droppingServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	d.mu.Lock()
+	d.requests++
+	drop := d.requests <= d.drops
+	d.mu.Unlock()
+
+	if drop {
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if err == nil {
+			conn.Close()
+		}
+		return
+	}
+	json.NewEncoder(w).Encode(SearchResponse{})
+}
+
+// requestCount returns the number of requests received so far
+func (d *droppingServer) requestCount() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.requests
+}
+
+func TestRequestRetriesDroppedConnection(t *testing.T) {
+	tests := []struct {
+		name         string
+		drops        int
+		wantErr      bool
+		wantRequests int
+	}{
+		{"no drops", 0, false, 1},
+		{"dropped once is retried transparently", 1, false, 2},
+		{"dropped twice is only retried once", 2, true, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := &droppingServer{drops: tt.drops}
+			c := newTestGeniusClient(t, server)
+
+			_, err := c.search(t.Context(), "Artist Title")
+			if (err != nil) != tt.wantErr {
+				t.Errorf("search error = %v, want error %v", err, tt.wantErr)
+			}
+			if n := server.requestCount(); n != tt.wantRequests {
+				t.Errorf("made %d requests, want %d", n, tt.wantRequests)
+			}
+		})
+	}
+}
+
+func TestIsTransientNetError(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{&url.Error{Op: "Get", Err: syscall.ECONNRESET}, true},
+		{&url.Error{Op: "Get", Err: io.EOF}, true},
+		{errors.Wrap(io.ErrUnexpectedEOF, "read body"), true},
+		{&url.Error{Op: "Get", Err: syscall.ECONNREFUSED}, false},
+		{context.Canceled, false},
+	}
+
+	for _, tt := range tests {
+		if got := isTransientNetError(tt.err); got != tt.want {
+			t.Errorf("isTransientNetError(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestNewTransport(t *testing.T) {
+	tr := newTransport()
+	if !tr.ForceAttemptHTTP2 {
+		t.Error("ForceAttemptHTTP2 = false, want HTTP/2 attempted")
+	}
+	if tr.IdleConnTimeout <= 0 || tr.IdleConnTimeout > time.Minute {
+		t.Errorf("IdleConnTimeout = %v, want idle connections closed within a minute", tr.IdleConnTimeout)
+	}
+	if tr.Proxy == nil {
+		t.Error("Proxy = nil, want the environment's proxy settings kept")
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, 1, 2, 15, 4, 5, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		header   string
+		wantWait time.Duration
+		wantOK   bool
+	}{
+		{"seconds", "3", 3 * time.Second, true},
+		{"seconds with spaces", " 120 ", 2 * time.Minute, true},
+		{"zero seconds", "0", 0, true},
+		{"negative seconds", "-1", 0, false},
+		{"HTTP date", "Tue, 02 Jan 2024 15:04:15 GMT", 10 * time.Second, true},
+		{"HTTP date in the past", "Tue, 02 Jan 2024 15:00:00 GMT", 0, true},
+		{"no header", "", 0, false},
+		{"invalid", "soon", 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			wait, ok := parseRetryAfter(tt.header, now)
+			if wait != tt.wantWait || ok != tt.wantOK {
+				t.Errorf("parseRetryAfter(%q) = %v, %v, want %v, %v", tt.header, wait, ok, tt.wantWait, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestRequestHonorsRetryAfter(t *testing.T) {
+	tests := []struct {
+		name         string
+		retryAfter   string
+		wantMin      time.Duration
+		wantMax      time.Duration
+		wantRequests int
+	}{
+		{"seconds", "1", time.Second, time.Second + retryBaseDelay, 2},
+		{"HTTP date", "in 2s", time.Second, 2*time.Second + retryBaseDelay, 2},
+		{"no header uses the backoff", "", retryBaseDelay, retryBaseDelay + retryBaseDelay/2 + 200*time.Millisecond, 2},
+		{"too long gives up", "3600", 0, retryBaseDelay, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			server := &flakyServer{failures: []int{http.StatusTooManyRequests}, header: http.Header{}}
+			switch tt.retryAfter {
+			case "":
+			case "in 2s":
+				// Dates are to the second, so the wait is between 1s and 2s
+				server.header.Set("Retry-After", time.Now().Add(2*time.Second).UTC().Format(http.TimeFormat))
+			default:
+				server.header.Set("Retry-After", tt.retryAfter)
+			}
+			c := newTestGeniusClient(t, server)
+
+			start := time.Now()
+			c.search(t.Context(), "Artist Title")
+			elapsed := time.Since(start)
+
+			if elapsed < tt.wantMin || elapsed > tt.wantMax {
+				t.Errorf("search took %v, want between %v and %v", elapsed, tt.wantMin, tt.wantMax)
+			}
+			if n := server.requestCount(); n != tt.wantRequests {
+				t.Errorf("made %d requests, want %d", n, tt.wantRequests)
+			}
+		})
+	}
+}
+
+func TestRetryAfterWaitCancelled(t *testing.T) {
+	server := &flakyServer{failures: []int{http.StatusTooManyRequests}, header: http.Header{"Retry-After": {"20"}}}
+	c := newTestGeniusClient(t, server)
+
+	ctx, cancel := context.WithCancel(t.Context())
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	start := time.Now()
+	_, err := c.search(ctx, "Artist Title")
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("search error = %v, want context.Canceled", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("search took %v, want it to stop waiting when cancelled", elapsed)
+	}
+}
+
+// largeSongPage returns the song fixture with its lyrics repeated until the
+// page is several hundred kilobytes, like the page of a long song
+func largeSongPage(b *testing.B) string {
+	b.Helper()
+	page, err := os.ReadFile("testdata/genius/song.html")
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	start := bytes.Index(page, []byte(`<div data-lyrics-container`))
+	end := bytes.Index(page, []byte(`<div class="Footer">`))
+	if start < 0 || end < start {
+		b.Fatal("lyrics not found in the fixture")
+	}
+	lyrics := string(page[start:end])
+	return string(page[:start]) + strings.Repeat(lyrics, 500) + string(page[end:])
+}
+
+// extractLyricsDoubleParse is the previous extraction, which rendered the
+// containers back to HTML and parsed that again to get its text
+func extractLyricsDoubleParse(doc *goquery.Document) string {
+	var lyricsHTML strings.Builder
+	doc.Find("[data-lyrics-container=\"true\"]").Each(func(i int, s *goquery.Selection) {
+		s.Find("[data-exclude-from-selection=\"true\"]").Remove()
+		html, _ := s.Html()
+		lyricsHTML.WriteString(strings.ReplaceAll(strings.ReplaceAll(html, "<br>", "\n"), "<br/>", "\n"))
+		lyricsHTML.WriteString("\n")
+	})
+
+	text, _ := goquery.NewDocumentFromReader(strings.NewReader(lyricsHTML.String()))
+	return text.Text()
+}
+
+// extractLyricsSingleParse extracts the text from the parsed page directly,
+// as getLyrics does
+func extractLyricsSingleParse(doc *goquery.Document) string {
+	var lyricsText strings.Builder
+	doc.Find("[data-lyrics-container=\"true\"]").Each(func(i int, s *goquery.Selection) {
+		s.Find("[data-exclude-from-selection=\"true\"]").Remove()
+		if i > 0 {
+			lyricsText.WriteString("\n")
+		}
+		for _, node := range s.Nodes {
+			writeNodeText(&lyricsText, node)
+		}
+	})
+	return lyricsText.String()
+}
+
+func BenchmarkExtractLyrics(b *testing.B) {
+	page := largeSongPage(b)
+	approaches := []struct {
+		name    string
+		extract func(*goquery.Document) string
+	}{
+		{"DoubleParse", extractLyricsDoubleParse},
+		{"SingleParse", extractLyricsSingleParse},
+	}
+
+	for _, approach := range approaches {
+		b.Run(approach.name, func(b *testing.B) {
+			b.SetBytes(int64(len(page)))
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				doc, err := goquery.NewDocumentFromReader(strings.NewReader(page))
+				if err != nil {
+					b.Fatal(err)
+				}
+				if approach.extract(doc) == "" {
+					b.Fatal("no lyrics extracted")
+				}
+			}
+		})
+	}
+}
+
+// scrapeFixture returns the lyrics scraped from a saved Genius page in
+// testdata/genius
+func scrapeFixture(t *testing.T, name string) string {
+	t.Helper()
+	c := newTestGeniusClient(t, http.FileServer(http.Dir("testdata/genius")))
+	lyrics, err := c.getLyrics(t.Context(), "/"+name)
+	if err != nil {
+		t.Fatalf("getLyrics(%s): %v", name, err)
+	}
+	return lyrics
+}
+
+func TestRemoveRecommendations(t *testing.T) {
+	tests := []struct {
+		name   string
+		lyrics string
+		want   string
+	}{
+		{"own line", "First line\nYou might also like\nSecond line", "First line\nSecond line"},
+		{"own line with spaces", "First line\n  You might also like \nSecond line", "First line\nSecond line"},
+		{"glued to a line", "Than the stars above usYou might also like\nNext", "Than the stars above us\nNext"},
+		{"lyric starting with the phrase", "You might also like the way I move", "You might also like the way I move"},
+		{"lyric ending with the phrase", "I know that you might also like", "I know that you might also like"},
+		{"lyric containing like", "I'd like to stay\nLike a rolling stone", "I'd like to stay\nLike a rolling stone"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := removeRecommendations(tt.lyrics); got != tt.want {
+				t.Errorf("removeRecommendations(%q) = %q, want %q", tt.lyrics, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetLyricsRemovesRecommendations(t *testing.T) {
+	lyrics := scrapeFixture(t, "song.html")
+
+	for _, line := range strings.Split(lyrics, "\n") {
+		if strings.TrimSpace(line) == "You might also like" || strings.HasSuffix(line, "usYou might also like") {
+			t.Errorf("recommendation left in the lyrics: %q", line)
+		}
+		if strings.Contains(line, "Other Song") {
+			t.Errorf("recommended song left in the lyrics: %q", line)
+		}
+	}
+	for _, want := range []string{"I'd like to stay a little longer", "You might also like the way I move", "Than the stars above us"} {
+		if !strings.Contains(lyrics, want+"\n") {
+			t.Errorf("lyric line %q missing from:\n%s", want, lyrics)
+		}
+	}
+}
+
+// update rewrites golden files with the current output instead of comparing
+// against them, for use after an intended change: go test -update
+var updateGolden = flag.Bool("update", false, "update golden files")
+
+// checkGolden compares got against the golden file at path
+func checkGolden(t *testing.T, path, got string) {
+	t.Helper()
+	if *updateGolden {
+		if err := os.WriteFile(path, []byte(got), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != string(want) {
+		t.Errorf("output doesn't match %s\ngot:\n%s\nwant:\n%s", path, got, want)
+	}
+}
+
+func TestGetLyricsSectionHeaders(t *testing.T) {
+	lyrics := scrapeFixture(t, "song.html")
+	checkGolden(t, "testdata/genius/song.golden", lyrics)
+	checkGolden(t, "testdata/genius/song-without-headers.golden", removeSectionHeaders(lyrics))
+}
+
+func TestFormatSectionHeaders(t *testing.T) {
+	tests := []struct {
+		name   string
+		lyrics string
+		want   string
+	}{
+		{"header first", "[Verse 1]\nFirst line", "[Verse 1]\nFirst line"},
+		{"blank line added before a header", "First line\n[Chorus]\nSecond line", "First line\n\n[Chorus]\nSecond line"},
+		{"blank line kept before a header", "First line\n\n[Chorus]\nSecond line", "First line\n\n[Chorus]\nSecond line"},
+		{"glued header split off", "First line[Chorus]\nSecond line", "First line\n\n[Chorus]\nSecond line"},
+		{"header with artist", "First line\n[Verse 2: Jay-Z]", "First line\n\n[Verse 2: Jay-Z]"},
+		{"brackets inside a line", "I said [laughs] and left", "I said [laughs] and left"},
+		{"spaced bracket at the end", "Sing it [x2]", "Sing it [x2]"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := formatSectionHeaders(tt.lyrics); got != tt.want {
+				t.Errorf("formatSectionHeaders(%q) = %q, want %q", tt.lyrics, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRemoveSectionHeaders(t *testing.T) {
+	tests := []struct {
+		name   string
+		lyrics string
+		want   string
+	}{
+		{"headers dropped", "[Verse 1]\nFirst line\n\n[Chorus]\nSecond line", "First line\n\nSecond line"},
+		{"blank lines collapsed", "First line\n\n[Bridge]\n\nSecond line", "First line\n\nSecond line"},
+		{"no headers", "First line\nSecond line", "First line\nSecond line"},
+		{"brackets inside a line kept", "I said [laughs] and left", "I said [laughs] and left"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := removeSectionHeaders(tt.lyrics); got != tt.want {
+				t.Errorf("removeSectionHeaders(%q) = %q, want %q", tt.lyrics, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFindSongSearchesCleanedTitle(t *testing.T) {
+	genius := &fakeGenius{results: map[string][]SearchHit{
+		"Jay-Z Empire State of Mind": {hit(5, "Jay-Z", "Empire State of Mind")},
+	}}
+	c := newTestGeniusClient(t, genius)
+
+	song, err := c.findSong(t.Context(), "Jay-Z", "The Blueprint 3 (Deluxe)", "Empire State of Mind (feat. Alicia Keys) - 2009 Remaster")
+	if err != nil {
+		t.Fatalf("findSong: %v", err)
+	}
+	if got := song.Response.Song.ID; got != 5 {
+		t.Errorf("found song %d, want 5", got)
+	}
+	if got, want := genius.searchedQueries(), []string{"Jay-Z Empire State of Mind"}; strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("searched for %q, want %q", got, want)
+	}
+}
+
+func TestGetLyricsWithoutAccessToken(t *testing.T) {
+	var requests atomic.Int32
+	c := newTestGeniusClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	c.accessToken = ""
+
+	_, err := c.GetLyrics(t.Context(), "Artist", "", "Title")
+	if !errors.Is(err, errNoAccessToken) {
+		t.Errorf("GetLyrics error = %v, want errNoAccessToken", err)
+	}
+	if n := requests.Load(); n != 0 {
+		t.Errorf("made %d requests without an access token, want none", n)
+	}
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"os"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/pkg/errors"
+)
+
+// Formats of the play history log, see Config.HistoryFormat
+const (
+	historyFormatJSONL = "jsonl"
+	historyFormatCSV   = "csv"
+)
+
+// historyEntry is a single played song in the history log
+type historyEntry struct {
+	Timestamp time.Time `json:"timestamp"`
+	Artist    string    `json:"artist"`
+	Album     string    `json:"album"`
+	Title     string    `json:"title"`
+}
+
+// historyWrittenMsg reports the result of appending to the history log
+type historyWrittenMsg struct {
+	err error
+}
+
+// appendHistory appends the entry to the history log at path in the given
+// format. A leading ~ in path is expanded to the home directory.
+func appendHistory(path, format string, entry historyEntry) error {
+	f, err := os.OpenFile(expandHome(path), os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return errors.Wrap(err, "open history file")
+	}
+	defer f.Close()
+
+	if format == historyFormatCSV {
+		w := csv.NewWriter(f)
+		record := []string{entry.Timestamp.Format(time.RFC3339), entry.Artist, entry.Album, entry.Title}
+		if err := w.Write(record); err != nil {
+			return errors.Wrap(err, "write history entry")
+		}
+		w.Flush()
+		return errors.Wrap(w.Error(), "write history entry")
+	}
+
+	if err := json.NewEncoder(f).Encode(entry); err != nil {
+		return errors.Wrap(err, "write history entry")
+	}
+	return nil
+}
+
+// appendHistoryCmd is a command to record a played song in the history log
+func appendHistoryCmd(path, format, artist, album, title string) tea.Cmd {
+	return func() tea.Msg {
+		err := appendHistory(path, format, historyEntry{
+			Timestamp: time.Now(),
+			Artist:    artist,
+			Album:     album,
+			Title:     title,
+		})
+		return historyWrittenMsg{err: err}
+	}
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestAppendHistory(t *testing.T) {
+	entry := historyEntry{
+		Timestamp: time.Date(2024, 1, 2, 15, 4, 5, 0, time.UTC),
+		Artist:    "Radiohead",
+		Album:     "OK Computer",
+		Title:     "Paranoid Android",
+	}
+
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{historyFormatJSONL, `{"timestamp":"2024-01-02T15:04:05Z","artist":"Radiohead","album":"OK Computer","title":"Paranoid Android"}` + "\n"},
+		{historyFormatCSV, "2024-01-02T15:04:05Z,Radiohead,OK Computer,Paranoid Android\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "history")
+			for i := 0; i < 2; i++ {
+				if err := appendHistory(path, tt.format, entry); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			got, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want+tt.want {
+				t.Errorf("history = %q, want %q twice", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAppendHistoryExpandsHome(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Chdir(t.TempDir())
+
+	if err := appendHistory("~/history.jsonl", historyFormatJSONL, historyEntry{Artist: "Artist", Title: "Title"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(home, "history.jsonl")); err != nil {
+		t.Errorf("history not written to the home directory: %v", err)
+	}
+	if _, err := os.Stat("~"); !os.IsNotExist(err) {
+		t.Error("created a literal ~ directory")
+	}
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/pkg/errors"
+)
+
+// songChangeHookMsg reports the result of running the song change hook
+type songChangeHookMsg struct {
+	err error
+}
+
+// runSongChangeHookCmd runs the user's song change command through the
+// shell. The song info is passed both as the positional arguments $1
+// (artist), $2 (album) and $3 (title), and as the LYRICS_ARTIST,
+// LYRICS_ALBUM and LYRICS_TITLE environment variables.
+func runSongChangeHookCmd(command, artist, album, title string) tea.Cmd {
+	return func() tea.Msg {
+		cmd := exec.Command("sh", "-c", command, "sh", artist, album, title)
+		cmd.Env = append(os.Environ(),
+			"LYRICS_ARTIST="+artist,
+			"LYRICS_ALBUM="+album,
+			"LYRICS_TITLE="+title,
+		)
+
+		if output, err := cmd.CombinedOutput(); err != nil {
+			return songChangeHookMsg{err: errors.Wrapf(err, "song change command: %s", output)}
+		}
+		return songChangeHookMsg{}
+	}
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Actions that can be bound to keys, see Config.Keybindings
+const (
+	actionScrollDown = "scroll_down"
+	actionScrollUp   = "scroll_up"
+	actionTop        = "top"
+	actionBottom     = "bottom"
+	actionPageDown   = "page_down"
+	actionPageUp     = "page_up"
+	actionRefresh    = "refresh"
+	actionQuit       = "quit"
+)
+
+// actions lists the bindable actions in a fixed order, for stable errors
+var actions = []string{
+	actionScrollDown, actionScrollUp, actionTop, actionBottom,
+	actionPageDown, actionPageUp, actionRefresh, actionQuit,
+}
+
+// defaultKeybindings are the keys for each action unless configured
+var defaultKeybindings = map[string][]string{
+	actionScrollDown: {"j", "down"},
+	actionScrollUp:   {"k", "up"},
+	actionTop:        {"g"},
+	actionBottom:     {"G"},
+	actionPageDown:   {"ctrl+d", "pgdown"},
+	actionPageUp:     {"ctrl+u", "pgup"},
+	actionRefresh:    {"r"},
+	actionQuit:       {"q"},
+}
+
+// reservedKeys are the fixed keys of other commands, which can't be bound to
+// an action. Each key has a single command, as a key can only do one thing.
+var reservedKeys = map[string]string{
+	"ctrl+c": "quit",
+	"esc":    "cancel",
+	"p":      "freeze",
+	"i":      "annotation",
+	"y":      "copy",
+	"s":      "save",
+	"/":      "search",
+	"n":      "next match",
+	"N":      "previous match",
+	"o":      "view note",
+	"O":      "edit note",
+	"a":      "auto-scroll",
+	"b":      "bold",
+	"#":      "line numbers",
+	"A":      "fix artist",
+	"T":      "fix title",
+	"t":      "selected track",
+}
+
+// scrollActions are the actions that scroll the lyrics by hand
+var scrollActions = map[string]bool{
+	actionScrollDown: true, actionScrollUp: true, actionTop: true,
+	actionBottom: true, actionPageDown: true, actionPageUp: true,
+}
+
+// resolveKeybindings returns the keys for each action, with configured keys
+// in place of the defaults. Unknown actions, reserved keys and keys bound to
+// more than one action are rejected.
+func resolveKeybindings(configured map[string]string) (map[string][]string, error) {
+	bindings := make(map[string][]string, len(actions))
+	for _, action := range actions {
+		bindings[action] = defaultKeybindings[action]
+	}
+
+	for action, key := range configured {
+		if _, ok := defaultKeybindings[action]; !ok {
+			return nil, errors.Errorf("invalid keybindings action %q: must be one of %s", action, strings.Join(actions, ", "))
+		}
+		if key == "" {
+			return nil, errors.Errorf("invalid keybindings entry for %s: key is empty", action)
+		}
+		if command, ok := reservedKeys[key]; ok {
+			return nil, errors.Errorf("invalid keybindings entry for %s: %q is reserved for %s", action, key, command)
+		}
+		bindings[action] = []string{key}
+	}
+
+	boundTo := make(map[string]string)
+	for _, action := range actions {
+		for _, key := range bindings[action] {
+			if other, ok := boundTo[key]; ok {
+				return nil, errors.Errorf("conflicting keybindings: %q is bound to both %s and %s", key, other, action)
+			}
+			boundTo[key] = action
+		}
+	}
+	return bindings, nil
+}
+
+// keyActions maps each key to the action it's bound to
+func keyActions(bindings map[string][]string) map[string]string {
+	keys := make(map[string]string)
+	for action, actionKeys := range bindings {
+		for _, key := range actionKeys {
+			keys[key] = action
+		}
+	}
+	return keys
+}
+
+// keyLabel formats a key for the help text, such as "C-d" for "ctrl+d"
+func keyLabel(key string) string {
+	if rest, ok := strings.CutPrefix(key, "ctrl+"); ok {
+		return "C-" + rest
+	}
+	return key
+}
+
+// buildHelpText returns the footer help text for the keybindings, and a
+// compact version for narrow terminals
+func buildHelpText(bindings map[string][]string) (full, compact string) {
+	key := func(action string) string {
+		return keyLabel(bindings[action][0])
+	}
+
+	full = fmt.Sprintf("%s/%s: scroll • %s/%s: top/bottom • %s/%s: page down/up • a: auto-scroll • b: bold • #: line numbers • p: freeze • A/T: fix artist/title • i: annotation • y: copy • s: save • /: search • n/N: next/prev match • o/O: view/edit note • %s: refresh • esc: cancel • %s: quit",
+		key(actionScrollDown), key(actionScrollUp), key(actionTop), key(actionBottom),
+		key(actionPageDown), key(actionPageUp), key(actionRefresh), key(actionQuit))
+	compact = fmt.Sprintf("%s/%s %s/%s %s/%s a b # p A/T i y s / n/N o/O %s esc %s",
+		key(actionScrollDown), key(actionScrollUp), key(actionTop), key(actionBottom),
+		key(actionPageDown), key(actionPageUp), key(actionRefresh), key(actionQuit))
+	return full, compact
+}
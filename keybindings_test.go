@@ -0,0 +1,91 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestResolveKeybindings(t *testing.T) {
+	tests := []struct {
+		name       string
+		configured map[string]string
+		wantErr    bool
+		want       map[string][]string
+	}{
+		{
+			name: "defaults",
+			want: defaultKeybindings,
+		},
+		{
+			name:       "configured key replaces the defaults",
+			configured: map[string]string{"scroll_down": "J", "scroll_up": "K"},
+			want: map[string][]string{
+				actionScrollDown: {"J"},
+				actionScrollUp:   {"K"},
+			},
+		},
+		{
+			name:       "unknown action",
+			configured: map[string]string{"jump": "J"},
+			wantErr:    true,
+		},
+		{
+			name:       "empty key",
+			configured: map[string]string{"quit": ""},
+			wantErr:    true,
+		},
+		{
+			name:       "conflicting keys",
+			configured: map[string]string{"quit": "j"},
+			wantErr:    true,
+		},
+		{
+			name:       "selected track toggle is reserved",
+			configured: map[string]string{"scroll_down": "t"},
+			wantErr:    true,
+		},
+		{
+			name:       "search navigation is reserved",
+			configured: map[string]string{"scroll_up": "n"},
+			wantErr:    true,
+		},
+		{
+			name:       "note keys are reserved",
+			configured: map[string]string{"scroll_down": "o"},
+			wantErr:    true,
+		},
+		{
+			name:       "ctrl+c is reserved",
+			configured: map[string]string{"refresh": "ctrl+c"},
+			wantErr:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bindings, err := resolveKeybindings(tt.configured)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("resolveKeybindings(%v) = %v, want an error", tt.configured, bindings)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("resolveKeybindings(%v): %v", tt.configured, err)
+			}
+			for action, want := range tt.want {
+				if got := bindings[action]; !reflect.DeepEqual(got, want) {
+					t.Errorf("bindings[%s] = %v, want %v", action, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestDefaultKeybindingsAvoidReservedKeys(t *testing.T) {
+	for key := range reservedKeys {
+		if action, ok := keyActions(defaultKeybindings)[key]; ok {
+			t.Errorf("reserved key %q is bound to %s by default", key, action)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+
+	"github.com/pkg/errors"
+	"golang.org/x/term"
+)
+
+// The access token is stored in the system keyring under this service and
+// account
+const (
+	keyringService = "cmus-lyrics"
+	keyringAccount = "genius_access_token"
+)
+
+// keyringToken reads the access token from the system keyring, using the
+// macOS keychain or the Secret Service on Linux. It returns an empty string
+// if there's no keyring or no token in it.
+func keyringToken() string {
+	var cmd *exec.Cmd
+	if runtime.GOOS == "darwin" {
+		cmd = exec.Command("security", "find-generic-password", "-s", keyringService, "-a", keyringAccount, "-w")
+	} else {
+		cmd = exec.Command("secret-tool", "lookup", "service", keyringService, "account", keyringAccount)
+	}
+
+	output, err := cmd.Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(output))
+}
+
+// setKeyringToken stores the access token in the system keyring, replacing
+// any stored earlier
+func setKeyringToken(token string) error {
+	var cmd *exec.Cmd
+	if runtime.GOOS == "darwin" {
+		// Passing the token as an argument would expose it to other users
+		// through the process list, so the command is fed to security's
+		// interactive mode on stdin instead
+		cmd = exec.Command("security", "-i")
+		cmd.Stdin = strings.NewReader(securityAddPasswordCommand(token))
+	} else {
+		// secret-tool reads the secret from stdin
+		cmd = exec.Command("secret-tool", "store", "--label=cmus-lyrics Genius access token",
+			"service", keyringService, "account", keyringAccount)
+		cmd.Stdin = strings.NewReader(token)
+	}
+
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return errors.Wrapf(err, "store token in keyring: %s", strings.TrimSpace(string(output)))
+	}
+	// security's interactive mode exits successfully even when the command
+	// it ran failed, so its error message has to be looked for instead
+	if runtime.GOOS == "darwin" && strings.Contains(string(output), "security: ") {
+		return errors.Errorf("store token in keyring: %s", strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// securityAddPasswordCommand returns the command that stores the token in
+// the macOS keychain, for security's interactive mode
+func securityAddPasswordCommand(token string) string {
+	return fmt.Sprintf("add-generic-password -U -s %s -a %s -w %s\n",
+		securityQuote(keyringService), securityQuote(keyringAccount), securityQuote(token))
+}
+
+// securityQuote quotes an argument for security's interactive mode, which
+// splits its input on whitespace outside of double quotes
+func securityQuote(arg string) string {
+	arg = strings.ReplaceAll(arg, `\`, `\\`)
+	arg = strings.ReplaceAll(arg, `"`, `\"`)
+	return `"` + arg + `"`
+}
+
+// readToken reads the access token from stdin, without echoing it if stdin
+// is a terminal
+func readToken() (string, error) {
+	fd := int(os.Stdin.Fd())
+	if term.IsTerminal(fd) {
+		fmt.Fprint(os.Stderr, "Genius access token: ")
+		token, err := term.ReadPassword(fd)
+		fmt.Fprintln(os.Stderr)
+		return strings.TrimSpace(string(token)), errors.Wrap(err, "read token")
+	}
+
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && line == "" {
+		return "", errors.Wrap(err, "read token")
+	}
+	return strings.TrimSpace(line), nil
+}
+
+// runSetTokenCommand prompts for the access token and stores it in the
+// system keyring
+func runSetTokenCommand() {
+	token, err := readToken()
+	if err != nil {
+		log.Fatal(err)
+	}
+	if token == "" {
+		log.Fatal("no token entered")
+	}
+
+	if err := setKeyringToken(token); err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println("Stored the access token in the system keyring")
+}
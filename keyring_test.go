@@ -0,0 +1,20 @@
+package main
+
+import "testing"
+
+func TestSecurityAddPasswordCommand(t *testing.T) {
+	tests := []struct {
+		token string
+		want  string
+	}{
+		{"abc-123_XYZ", `add-generic-password -U -s "cmus-lyrics" -a "genius_access_token" -w "abc-123_XYZ"` + "\n"},
+		{`with "quotes"`, `add-generic-password -U -s "cmus-lyrics" -a "genius_access_token" -w "with \"quotes\""` + "\n"},
+		{`back\slash`, `add-generic-password -U -s "cmus-lyrics" -a "genius_access_token" -w "back\\slash"` + "\n"},
+	}
+
+	for _, tt := range tests {
+		if got := securityAddPasswordCommand(tt.token); got != tt.want {
+			t.Errorf("securityAddPasswordCommand(%q) = %q, want %q", tt.token, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	// lrcTimestampPattern matches LRC timestamps such as "[01:23.45]"
+	lrcTimestampPattern = regexp.MustCompile(`\[\d+:\d+(?:[.:]\d+)?\]`)
+
+	// lrcMetadataPattern matches LRC metadata lines such as "[ar:Artist]"
+	lrcMetadataPattern = regexp.MustCompile(`^\[[a-zA-Z]+:.*\]$`)
+)
+
+// LocalFileProvider looks up lyrics in a directory of files named
+// "Artist - Title.lrc" or "Artist - Title.txt"
+type LocalFileProvider struct {
+	dir string
+}
+
+func NewLocalFileProvider(dir string) *LocalFileProvider {
+	c := &LocalFileProvider{
+		dir: expandHome(dir),
+	}
+	return c
+}
+
+// normalizeFilename lowercases s and drops everything except letters and
+// digits, so that differences in case, separators and punctuation don't
+// prevent a match
+func normalizeFilename(s string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(s) {
+		if ('a' <= r && r <= 'z') || ('0' <= r && r <= '9') || r > 127 {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// localMatchThreshold is the minimum score for a file to be considered a
+// match, see scoreFilename
+const localMatchThreshold = 0.7
+
+// filenameTokens splits s into lowercase words of letters and digits
+func filenameTokens(s string) []string {
+	return strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// scoreFilename scores how well a file name matches the artist and title,
+// from 0 to 1, by the overlap of their words. Missing words from the artist
+// and title weigh more than extra words in the file name, such as "(Live)",
+// and a file matching none of the title's words never scores.
+func scoreFilename(name, artist, title string) float64 {
+	nameTokens := make(map[string]bool)
+	for _, token := range filenameTokens(name) {
+		nameTokens[token] = true
+	}
+
+	titleTokens := filenameTokens(title)
+	wanted := append(filenameTokens(artist), titleTokens...)
+	if len(wanted) == 0 || len(nameTokens) == 0 {
+		return 0
+	}
+
+	matched, titleMatched := 0, 0
+	for i, token := range wanted {
+		if nameTokens[token] {
+			matched++
+			if i >= len(wanted)-len(titleTokens) {
+				titleMatched++
+			}
+		}
+	}
+	if len(titleTokens) > 0 && titleMatched == 0 {
+		return 0
+	}
+
+	recall := float64(matched) / float64(len(wanted))
+	precision := float64(matched) / float64(len(nameTokens))
+	if precision > 1 {
+		precision = 1
+	}
+	return 0.75*recall + 0.25*precision
+}
+
+// findFile returns the path of the lyrics file best matching the artist and
+// title. An exact match on the normalized name wins outright; otherwise the
+// highest scoring file above localMatchThreshold is used.
+func (p *LocalFileProvider) findFile(artist, title string) (string, error) {
+	entries, err := os.ReadDir(p.dir)
+	if err != nil {
+		return "", errors.Wrap(err, "read local lyrics directory")
+	}
+
+	want := normalizeFilename(artist + title)
+
+	var best string
+	bestScore := localMatchThreshold
+	for _, entry := range entries {
+		ext := strings.ToLower(filepath.Ext(entry.Name()))
+		if entry.IsDir() || (ext != ".lrc" && ext != ".txt") {
+			continue
+		}
+
+		base := strings.TrimSuffix(entry.Name(), filepath.Ext(entry.Name()))
+		if normalizeFilename(base) == want {
+			return filepath.Join(p.dir, entry.Name()), nil
+		}
+		if score := scoreFilename(base, artist, title); score >= bestScore {
+			best = filepath.Join(p.dir, entry.Name())
+			bestScore = score
+		}
+	}
+
+	if best == "" {
+		return "", &NotFoundError{Provider: providerLocal}
+	}
+	return best, nil
+}
+
+// GetSyncedLyrics reads synced lyrics for the song from an LRC file in the
+// local directory
+func (p *LocalFileProvider) GetSyncedLyrics(ctx context.Context, artist string, album string, title string, duration time.Duration) ([]LyricLine, error) {
+	path, err := p.findFile(artist, title)
+	if err != nil {
+		return nil, err
+	}
+	if strings.ToLower(filepath.Ext(path)) != ".lrc" {
+		return nil, &NotFoundError{Provider: providerLocal}
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "read local lyrics file")
+	}
+
+	lines := parseLRC(string(data))
+	if len(lines) == 0 {
+		return nil, &NotFoundError{Provider: providerLocal}
+	}
+	return lines, nil
+}
+
+// GetLyrics reads lyrics for the song from the local directory. LRC
+// timestamps and metadata tags are stripped.
+func (p *LocalFileProvider) GetLyrics(ctx context.Context, artist string, album string, title string) (string, error) {
+	path, err := p.findFile(artist, title)
+	if err != nil {
+		return "", err
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", errors.Wrap(err, "read local lyrics file")
+	}
+
+	if strings.ToLower(filepath.Ext(path)) != ".lrc" {
+		return strings.TrimSpace(string(data)), nil
+	}
+
+	var lines []string
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if lrcMetadataPattern.MatchString(line) {
+			continue
+		}
+		lines = append(lines, strings.TrimSpace(lrcTimestampPattern.ReplaceAllString(line, "")))
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n")), nil
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// localLyricsFixtures is a directory of lyrics files with oddly formatted
+// names
+const localLyricsFixtures = "testdata/local-lyrics"
+
+func TestScoreFilename(t *testing.T) {
+	tests := []struct {
+		name      string
+		artist    string
+		title     string
+		wantMatch bool
+	}{
+		{"Radiohead - Creep", "Radiohead", "Creep", true},
+		{"radiohead_creep", "Radiohead", "Creep", true},
+		{"The Beatles - Hey Jude (Remastered 2015)", "The Beatles", "Hey Jude", true},
+		{"01. Daft Punk – One More Time [Live]", "Daft Punk", "One More Time", true},
+		{"Radiohead - Karma Police", "Radiohead", "Creep", false},
+		{"Creep", "Radiohead", "Creep", false},
+		{"Radiohead", "Radiohead", "Creep", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			score := scoreFilename(tt.name, tt.artist, tt.title)
+			if got := score >= localMatchThreshold; got != tt.wantMatch {
+				t.Errorf("scoreFilename(%q, %q, %q) = %.2f, want match %v", tt.name, tt.artist, tt.title, score, tt.wantMatch)
+			}
+		})
+	}
+}
+
+func TestLocalFileProviderFindFile(t *testing.T) {
+	tests := []struct {
+		artist   string
+		title    string
+		wantFile string
+	}{
+		{"Radiohead", "Creep", "Radiohead - Creep.txt"},
+		{"radiohead", "CREEP", "Radiohead - Creep.txt"},
+		{"Radiohead", "Karma Police", "radiohead_karma_police.lrc"},
+		{"The Beatles", "Hey Jude", "The Beatles - Hey Jude (Remastered 2015).txt"},
+		{"Daft Punk", "One More Time", "01. Daft Punk – One More Time [Live].txt"},
+		{"Muse", "Uprising", "MUSE-Uprising.TXT"},
+
+		// Only .lrc and .txt files hold lyrics
+		{"Queen", "Bohemian Rhapsody", ""},
+		{"Radiohead", "No Surprises", ""},
+	}
+
+	p := NewLocalFileProvider(localLyricsFixtures)
+	for _, tt := range tests {
+		t.Run(tt.artist+" - "+tt.title, func(t *testing.T) {
+			path, err := p.findFile(tt.artist, tt.title)
+			if tt.wantFile == "" {
+				var notFound *NotFoundError
+				if !errors.As(err, &notFound) {
+					t.Errorf("findFile = %q, %v, want a NotFoundError", path, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("findFile: %v", err)
+			}
+			if got := filepath.Base(path); got != tt.wantFile {
+				t.Errorf("findFile = %q, want %q", got, tt.wantFile)
+			}
+		})
+	}
+}
+
+func TestLocalFileProviderGetLyrics(t *testing.T) {
+	p := NewLocalFileProvider(localLyricsFixtures)
+
+	lyrics, err := p.GetLyrics(context.Background(), "Radiohead", "", "Karma Police")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "Karma police\nArrest this man"; lyrics != want {
+		t.Errorf("lyrics = %q, want %q with the LRC tags stripped", lyrics, want)
+	}
+
+	lines, err := p.GetSyncedLyrics(context.Background(), "Radiohead", "", "Karma Police", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []LyricLine{{Time: time.Second, Text: "Karma police"}, {Time: 5500 * time.Millisecond, Text: "Arrest this man"}}
+	if len(lines) != len(want) || lines[0] != want[0] || lines[1] != want[1] {
+		t.Errorf("synced lyrics = %v, want %v", lines, want)
+	}
+
+	// Plain text files have no synced lyrics
+	if _, err := p.GetSyncedLyrics(context.Background(), "Radiohead", "", "Creep", 0); err == nil {
+		t.Error("GetSyncedLyrics found synced lyrics in a .txt file")
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// LyricLine is a line of synced lyrics and the time into the song at which
+// it's sung
+type LyricLine struct {
+	Time time.Duration
+	Text string
+}
+
+// lrcLinePattern matches the timestamps at the start of a synced LRC line,
+// such as "[01:23.45]". A line sung more than once can have several.
+var lrcLinePattern = regexp.MustCompile(`^((?:\[\d+:\d{2}(?:[.:]\d{1,3})?\])+)(.*)$`)
+
+// lrcTimestampPartsPattern extracts the minutes, seconds and fraction of a
+// single LRC timestamp
+var lrcTimestampPartsPattern = regexp.MustCompile(`\[(\d+):(\d{2})(?:[.:](\d{1,3}))?\]`)
+
+// parseLRC parses synced lyrics in LRC format, sorted by time. Metadata tags
+// and lines without timestamps are skipped.
+func parseLRC(lrc string) []LyricLine {
+	var lines []LyricLine
+	for _, raw := range strings.Split(lrc, "\n") {
+		match := lrcLinePattern.FindStringSubmatch(strings.TrimSpace(raw))
+		if match == nil {
+			continue
+		}
+
+		text := strings.TrimSpace(match[2])
+		for _, ts := range lrcTimestampPartsPattern.FindAllStringSubmatch(match[1], -1) {
+			minutes, _ := strconv.Atoi(ts[1])
+			seconds, _ := strconv.Atoi(ts[2])
+			t := time.Duration(minutes)*time.Minute + time.Duration(seconds)*time.Second
+
+			// The fraction is hundredths in most files, but may be tenths
+			// or milliseconds
+			if fraction := ts[3]; fraction != "" {
+				n, _ := strconv.Atoi(fraction)
+				for i := len(fraction); i < 3; i++ {
+					n *= 10
+				}
+				t += time.Duration(n) * time.Millisecond
+			}
+
+			lines = append(lines, LyricLine{Time: t, Text: text})
+		}
+	}
+
+	sort.SliceStable(lines, func(i, j int) bool {
+		return lines[i].Time < lines[j].Time
+	})
+	return lines
+}
+
+// lyricLinesText returns the text of synced lyrics without their timestamps
+func lyricLinesText(lines []LyricLine) string {
+	texts := make([]string, len(lines))
+	for i, line := range lines {
+		texts[i] = line.Text
+	}
+	return strings.TrimSpace(strings.Join(texts, "\n"))
+}
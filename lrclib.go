@@ -0,0 +1,207 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// lrclibBaseURL is the LRCLIB API, which serves synced lyrics for free
+// without an access token
+const lrclibBaseURL = "https://lrclib.net/api"
+
+// lrclibUserAgent identifies the app to LRCLIB, as its API docs request
+const lrclibUserAgent = "lyrics (https://github.com/benjaminheng/cmus-lyrics)"
+
+// LRCLIBTrack is a track returned by the LRCLIB API
+type LRCLIBTrack struct {
+	ID           int64   `json:"id"`
+	TrackName    string  `json:"trackName"`
+	ArtistName   string  `json:"artistName"`
+	AlbumName    string  `json:"albumName"`
+	Duration     float64 `json:"duration"`
+	Instrumental bool    `json:"instrumental"`
+	PlainLyrics  string  `json:"plainLyrics"`
+	SyncedLyrics string  `json:"syncedLyrics"`
+}
+
+type LRCLIBClient struct {
+	httpClient *http.Client
+	baseURL    string
+
+	// The track found by the last lyrics lookup, so that looking up its
+	// synced lyrics straight after doesn't query LRCLIB again
+	mu        sync.Mutex
+	lastKey   string
+	lastTrack LRCLIBTrack
+}
+
+// trackKey identifies a song in the cache of the last track found
+func trackKey(artist, album, title string) string {
+	return artist + "\x00" + album + "\x00" + title
+}
+
+// remember stores the track found for the song
+func (c *LRCLIBClient) remember(artist, album, title string, track LRCLIBTrack) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.lastKey = trackKey(artist, album, title)
+	c.lastTrack = track
+}
+
+// remembered returns the track last found for the song, if any
+func (c *LRCLIBClient) remembered(artist, album, title string) (LRCLIBTrack, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.lastKey != trackKey(artist, album, title) {
+		return LRCLIBTrack{}, false
+	}
+	return c.lastTrack, true
+}
+
+// NewLRCLIBClient creates an LRCLIB client. A zero timeout defaults to
+// defaultRequestTimeout.
+func NewLRCLIBClient(timeout time.Duration) *LRCLIBClient {
+	if timeout == 0 {
+		timeout = defaultRequestTimeout
+	}
+
+	c := &LRCLIBClient{
+		httpClient: &http.Client{Transport: newTransport(), Timeout: timeout},
+		baseURL:    lrclibBaseURL,
+	}
+	return c
+}
+
+// getJSON sends a GET request to the API endpoint and decodes the response
+// into v. A 404 is reported as a NotFoundError.
+func (c *LRCLIBClient) getJSON(ctx context.Context, endpoint string, params url.Values, v interface{}) error {
+	requestURL := c.baseURL + endpoint + "?" + params.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, requestURL, nil)
+	if err != nil {
+		return errors.Wrap(err, "create request")
+	}
+	req.Header.Set("User-Agent", lrclibUserAgent)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return errors.Wrap(err, "send request")
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return &NotFoundError{Provider: providerLRCLIB}
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return errors.Wrap(err, "decode response")
+	}
+	return nil
+}
+
+// getTrack looks up the track by its exact signature. LRCLIB matches the
+// duration to within a couple of seconds. The album is left out when it's
+// unknown, as an empty one would only match tracks without an album.
+func (c *LRCLIBClient) getTrack(ctx context.Context, artist, album, title string, duration time.Duration) (LRCLIBTrack, error) {
+	params := url.Values{}
+	params.Add("artist_name", artist)
+	params.Add("track_name", title)
+	if album != "" {
+		params.Add("album_name", album)
+	}
+	params.Add("duration", strconv.Itoa(int(duration.Round(time.Second).Seconds())))
+
+	var track LRCLIBTrack
+	err := c.getJSON(ctx, "/get", params, &track)
+	return track, err
+}
+
+// searchTrack returns the first search result accepted by the given function,
+// for when the duration isn't known
+func (c *LRCLIBClient) searchTrack(ctx context.Context, artist, album, title string, accept func(LRCLIBTrack) bool) (LRCLIBTrack, error) {
+	params := url.Values{}
+	params.Add("artist_name", artist)
+	params.Add("track_name", title)
+	if album != "" {
+		params.Add("album_name", album)
+	}
+
+	var tracks []LRCLIBTrack
+	if err := c.getJSON(ctx, "/search", params, &tracks); err != nil {
+		return LRCLIBTrack{}, err
+	}
+	for _, track := range tracks {
+		if accept(track) {
+			return track, nil
+		}
+	}
+	return LRCLIBTrack{}, &NotFoundError{Provider: providerLRCLIB}
+}
+
+// GetSyncedLyrics returns the time-synced lyrics for the song. The track
+// found by the lyrics lookup is reused if it has them; otherwise the track is
+// looked up by its duration if it's known, and searched for if it isn't or
+// no track has that exact signature. A NotFoundError is returned if LRCLIB
+// has no synced lyrics for the song.
+func (c *LRCLIBClient) GetSyncedLyrics(ctx context.Context, artist string, album string, title string, duration time.Duration) ([]LyricLine, error) {
+	if track, ok := c.remembered(artist, album, title); ok {
+		if lines := parseLRC(track.SyncedLyrics); len(lines) > 0 {
+			return lines, nil
+		}
+	}
+
+	var track LRCLIBTrack
+	var err error
+	if duration > 0 {
+		track, err = c.getTrack(ctx, artist, album, title, duration)
+	}
+
+	// Tags often differ slightly from LRCLIB's, such as in the album or the
+	// duration of a remaster, so search when the exact lookup misses
+	var notFound *NotFoundError
+	if duration <= 0 || errors.As(err, &notFound) {
+		track, err = c.searchTrack(ctx, artist, album, title, func(t LRCLIBTrack) bool {
+			return t.SyncedLyrics != ""
+		})
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "get track from lrclib")
+	}
+
+	lines := parseLRC(track.SyncedLyrics)
+	if len(lines) == 0 {
+		return nil, &NotFoundError{Provider: providerLRCLIB}
+	}
+	return lines, nil
+}
+
+// GetLyrics returns the plain lyrics for the song, so that LRCLIB can be used
+// as a LyricsProvider. Tracks with only synced lyrics have their timestamps
+// dropped.
+func (c *LRCLIBClient) GetLyrics(ctx context.Context, artist string, album string, title string) (string, error) {
+	track, err := c.searchTrack(ctx, artist, album, title, func(t LRCLIBTrack) bool {
+		return t.PlainLyrics != "" || t.SyncedLyrics != ""
+	})
+	if err != nil {
+		return "", errors.Wrap(err, "search lrclib")
+	}
+	c.remember(artist, album, title, track)
+
+	if track.PlainLyrics != "" {
+		return strings.TrimSpace(track.PlainLyrics), nil
+	}
+
+	return lyricLinesText(parseLRC(track.SyncedLyrics)), nil
+}
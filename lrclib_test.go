@@ -0,0 +1,206 @@
+package main
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// fakeLRCLIB serves the /get and /search endpoints from a fixed set of
+// tracks, and records the requests made to it
+type fakeLRCLIB struct {
+	tracks []LRCLIBTrack
+
+	mu       sync.Mutex
+	requests []string
+}
+
+func (f *fakeLRCLIB) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	f.requests = append(f.requests, r.URL.Path+"?"+r.URL.RawQuery)
+	f.mu.Unlock()
+
+	q := r.URL.Query()
+	matches := func(t LRCLIBTrack) bool {
+		return t.ArtistName == q.Get("artist_name") && t.TrackName == q.Get("track_name") &&
+			(!q.Has("album_name") || t.AlbumName == q.Get("album_name"))
+	}
+
+	switch r.URL.Path {
+	case "/get":
+		duration, _ := strconv.Atoi(q.Get("duration"))
+		for _, t := range f.tracks {
+			if matches(t) && math.Abs(t.Duration-float64(duration)) <= 2 {
+				json.NewEncoder(w).Encode(t)
+				return
+			}
+		}
+		http.NotFound(w, r)
+	case "/search":
+		results := []LRCLIBTrack{}
+		for _, t := range f.tracks {
+			if matches(t) {
+				results = append(results, t)
+			}
+		}
+		json.NewEncoder(w).Encode(results)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// paths returns the paths requested so far
+func (f *fakeLRCLIB) paths() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var paths []string
+	for _, r := range f.requests {
+		path, _, _ := strings.Cut(r, "?")
+		paths = append(paths, path)
+	}
+	return paths
+}
+
+// newTestLRCLIBClient returns a client that sends its requests to the fake
+func newTestLRCLIBClient(t *testing.T, f *fakeLRCLIB) *LRCLIBClient {
+	t.Helper()
+	server := httptest.NewServer(f)
+	t.Cleanup(server.Close)
+
+	c := NewLRCLIBClient(0)
+	c.baseURL = server.URL
+	return c
+}
+
+var paranoidAndroid = LRCLIBTrack{
+	ID:           1,
+	TrackName:    "Paranoid Android",
+	ArtistName:   "Radiohead",
+	AlbumName:    "OK Computer",
+	Duration:     383,
+	PlainLyrics:  "Please could you stop the noise\nI'm trying to get some rest",
+	SyncedLyrics: "[00:36.50] Please could you stop the noise\n[00:40.20] I'm trying to get some rest",
+}
+
+func TestLRCLIBGetTrackAlbum(t *testing.T) {
+	tests := []struct {
+		name      string
+		album     string
+		wantQuery string
+	}{
+		{"album given", "OK Computer", "album_name=OK+Computer&artist_name=Radiohead&duration=383&track_name=Paranoid+Android"},
+		{"empty album left out", "", "artist_name=Radiohead&duration=383&track_name=Paranoid+Android"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := &fakeLRCLIB{tracks: []LRCLIBTrack{paranoidAndroid}}
+			c := newTestLRCLIBClient(t, f)
+
+			track, err := c.getTrack(t.Context(), "Radiohead", tt.album, "Paranoid Android", 383*time.Second)
+			if err != nil {
+				t.Fatalf("getTrack: %v", err)
+			}
+			if track.ID != paranoidAndroid.ID {
+				t.Errorf("found track %d, want %d", track.ID, paranoidAndroid.ID)
+			}
+			if want := "/get?" + tt.wantQuery; len(f.requests) != 1 || f.requests[0] != want {
+				t.Errorf("requested %q, want %q", f.requests, want)
+			}
+		})
+	}
+}
+
+func TestLRCLIBGetSyncedLyrics(t *testing.T) {
+	tests := []struct {
+		name      string
+		album     string
+		duration  time.Duration
+		wantErr   bool
+		wantPaths []string
+	}{
+		{"exact signature", "OK Computer", 383 * time.Second, false, []string{"/get"}},
+		{"unknown album", "", 384 * time.Second, false, []string{"/get"}},
+		{"unknown duration is searched for", "OK Computer", 0, false, []string{"/search"}},
+		{"duration mismatch falls back to search", "OK Computer", 390 * time.Second, false, []string{"/get", "/search"}},
+		{"not found by either lookup", "OK Computer (Remastered)", 383 * time.Second, true, []string{"/get", "/search"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := &fakeLRCLIB{tracks: []LRCLIBTrack{paranoidAndroid}}
+			c := newTestLRCLIBClient(t, f)
+
+			lines, err := c.GetSyncedLyrics(t.Context(), "Radiohead", tt.album, "Paranoid Android", tt.duration)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("GetSyncedLyrics error = %v, want error %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				var notFound *NotFoundError
+				if !errors.As(err, &notFound) {
+					t.Errorf("error %v isn't a NotFoundError", err)
+				}
+			} else if len(lines) != 2 || lines[0].Time != 36500*time.Millisecond {
+				t.Errorf("synced lyrics = %v, want the two timed lines", lines)
+			}
+			if got := f.paths(); strings.Join(got, " ") != strings.Join(tt.wantPaths, " ") {
+				t.Errorf("requested %v, want %v", got, tt.wantPaths)
+			}
+		})
+	}
+}
+
+func TestLRCLIBGetLyrics(t *testing.T) {
+	syncedOnly := paranoidAndroid
+	syncedOnly.PlainLyrics = ""
+
+	tests := []struct {
+		name       string
+		track      LRCLIBTrack
+		wantLyrics string
+	}{
+		{"plain lyrics", paranoidAndroid, "Please could you stop the noise\nI'm trying to get some rest"},
+		{"synced lyrics without timestamps", syncedOnly, "Please could you stop the noise\nI'm trying to get some rest"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := &fakeLRCLIB{tracks: []LRCLIBTrack{tt.track}}
+			c := newTestLRCLIBClient(t, f)
+
+			lyrics, err := c.GetLyrics(t.Context(), "Radiohead", "OK Computer", "Paranoid Android")
+			if err != nil {
+				t.Fatalf("GetLyrics: %v", err)
+			}
+			if lyrics != tt.wantLyrics {
+				t.Errorf("lyrics = %q, want %q", lyrics, tt.wantLyrics)
+			}
+
+			// The synced lyrics of the track just found are reused
+			if _, err := c.GetSyncedLyrics(t.Context(), "Radiohead", "OK Computer", "Paranoid Android", 383*time.Second); err != nil {
+				t.Fatalf("GetSyncedLyrics: %v", err)
+			}
+			if got := f.paths(); len(got) != 1 {
+				t.Errorf("requested %v, want only the search for the lyrics", got)
+			}
+		})
+	}
+}
+
+func TestLRCLIBGetLyricsNotFound(t *testing.T) {
+	c := newTestLRCLIBClient(t, &fakeLRCLIB{})
+
+	_, err := c.GetLyrics(t.Context(), "Radiohead", "", "Paranoid Android")
+	var notFound *NotFoundError
+	if !errors.As(err, &notFound) {
+		t.Errorf("GetLyrics error = %v, want a NotFoundError", err)
+	}
+}
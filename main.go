@@ -7,36 +7,229 @@ import (
 	"log"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"regexp"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/charmbracelet/bubbles/progress"
+	"github.com/charmbracelet/bubbles/spinner"
+	"github.com/charmbracelet/bubbles/textinput"
 	"github.com/charmbracelet/bubbles/viewport"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
+	"github.com/muesli/reflow/truncate"
+	"github.com/pkg/errors"
 )
 
 // Model represents the application state
 type model struct {
 	viewport        viewport.Model
 	showHelpFooter  bool
+	keys            map[string]string
+	player          Player
 	geniusAPIClient *GeniusAPIClient
+	lyricsProvider  *ChainProvider
+	palette         palette
+
+	// Whether the user may switch to following the selected cmus track,
+	// and whether we're currently following it instead of the playing one
+	enableSelectedTrack bool
+	followSelected      bool
+
+	// Lyrics longer than this many characters are truncated. Zero means no
+	// limit.
+	maxLyricsChars int
+
+	// How long cmus must report no song before we show it, and when it
+	// started doing so
+	noSongGrace time.Duration
+	noSongSince time.Time
+
+	// Show line numbers in a gutter to the left of the lyrics
+	showLineNumbers bool
+
+	// Vertically center lyrics that fit within the viewport
+	verticalCenter bool
+
+	// Render lyrics in bold for low-contrast terminals
+	boldLyrics bool
+
+	// Horizontal alignment of the lyrics, notes and annotations
+	alignment lipgloss.Position
+
+	// Rendered lyrics for recently used layouts
+	renderCache *renderCache
+
+	// Show only the song info without fetching lyrics
+	metadataOnly bool
+
+	// Shell command run whenever the song changes
+	songChangeCmd string
+
+	// Artists such as "Various Artists" that don't identify the performer
+	// and shouldn't be searched for
+	placeholderArtists []string
+
+	// Play history log that each song is appended to, and its format
+	historyFile   string
+	historyFormat string
+
+	// Clipboard for copying the lyrics, or nil if no tool is installed
+	clipboard Clipboard
+
+	// Where lyrics are saved to, see expandSavePathTemplate
+	savePathTemplate string
+
+	// Desktop notification tool, or empty if notifications are disabled,
+	// along with the last song notified about and when
+	notifier       string
+	notifiedSongID string
+	lastNotified   time.Time
 
 	statusBar   string
 	artist      string
 	album       string
 	title       string
+	albumArtist string
+	composer    string
+	playSource  string
 	lyrics      string
 	ready       bool
 	lastChecked time.Time
 
 	// Track if we've already fetched lyrics for the current song
 	currentSongID string
+
+	// ID of the song that the displayed lyrics belong to
+	lyricsSongID string
+
+	// Transient message shown in the footer, such as a failed refresh
+	footerMessage       string
+	footerMessageExpiry time.Time
+
+	// Field being edited ("artist", "title", "note" or "search"), or empty
+	// when not editing
+	editField string
+	editInput textinput.Model
+
+	// Search within the displayed lyrics
+	search searchState
+
+	// Manual artist/title corrections for this session, keyed by the song
+	// ID of the original tags
+	overrides map[string]songOverride
+
+	// Cancels the in-flight lyrics fetch, or nil if there is none
+	cancelFetch context.CancelFunc
+
+	// Cancels the in-flight fetch of synced lyrics, which follows the
+	// lyrics fetch
+	cancelSyncedFetch context.CancelFunc
+
+	// Cancels the in-flight fetch of Genius annotations
+	cancelAnnotationsFetch context.CancelFunc
+
+	// How long a song must stay current before its lyrics are fetched
+	fetchDebounce time.Duration
+
+	// Set when the user asks for the lyrics to be fetched again, and whether
+	// the scroll position is kept when they arrive
+	refreshRequested       bool
+	refreshPreservesScroll bool
+
+	// ID of the latest scheduled cmus check, how often cmus is checked, and
+	// whether polling is paused
+	checkID       int
+	pollInterval  time.Duration
+	pollingPaused bool
+
+	// Whether to stop polling while cmus is paused, and whether it has been
+	// stopped
+	pauseStopsPolling bool
+	pollingStopped    bool
+
+	// Genius annotations for the song identified by annotationsSongID
+	annotations       []Annotation
+	annotationsSongID string
+
+	// Whether an annotation or note is shown in place of the lyrics, and the
+	// lyrics scroll position to return to
+	showingAnnotation bool
+	annotationYOffset int
+
+	// Lyric line to show the annotation for once annotations have loaded
+	annotationLine string
+
+	// Whether to show if the song is playing from the library or a playlist
+	showPlaySource bool
+
+	// Whether to keep section headers such as "[Chorus]" in the lyrics
+	showSectionHeaders bool
+
+	// Personal notes keyed by song ID, and the file they're saved to
+	notes     map[string]string
+	notesFile string
+
+	// State shown in place of the lyrics, such as loading or an error, or
+	// empty when the lyrics are shown. See showState.
+	state       string
+	stateDetail string
+	stateStyle  stateStyle
+
+	// Spinner shown with the loading message, and whether it's animating
+	spinner  spinner.Model
+	spinning bool
+
+	// Keybindings listed in the footer. compactHelpText is used instead
+	// when the terminal is too narrow.
+	helpText        string
+	compactHelpText string
+
+	// Whether to show debugging info in the footer, and the info for the
+	// current lyrics
+	debug       bool
+	debugStatus string
+
+	// Playback position and duration of the song in seconds, when they were
+	// last checked, and the playback status
+	position       int
+	duration       int
+	positionAt     time.Time
+	playbackStatus string
+
+	// Bar in the footer showing how far through the song playback is
+	progress progress.Model
+
+	// Time-synced lyrics for the displayed lyrics, if any
+	syncedLyrics []LyricLine
+
+	// Whether the lyrics scroll along with playback, and when the user last
+	// scrolled by hand
+	autoScroll       bool
+	lastManualScroll time.Time
+
+	// ID of the latest scheduled playback tick
+	playbackTickID int
+}
+
+// songOverride replaces the tagged artist and title of a song
+type songOverride struct {
+	artist string
+	title  string
 }
 
+// pausedPollInterval is how often cmus is checked when polling has been
+// stopped because playback is paused
+const pausedPollInterval = time.Minute
+
+// footerMessageDuration is how long transient footer messages are shown
+const footerMessageDuration = 3 * time.Second
+
 // Init initializes the Bubble Tea program
 func (m model) Init() tea.Cmd {
-	return checkCmusCmd()
+	return m.checkSongCmd()
 }
 
 // Update handles events and updates the model
@@ -48,25 +241,114 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 	switch msg := msg.(type) {
 	case tea.KeyMsg:
-		switch msg.String() {
-		case "q", "ctrl+c":
+		// Any key resumes polling that was stopped while paused
+		if m.pollingStopped {
+			m.pollingStopped = false
+			cmds = append(cmds, m.checkSongCmd())
+		}
+
+		// Keys go to the input while editing a field
+		if m.editField != "" {
+			switch msg.String() {
+			case "enter":
+				cmds = append(cmds, m.applyEdit())
+			case "esc":
+				m.editField = ""
+			default:
+				m.editInput, cmd = m.editInput.Update(msg)
+				cmds = append(cmds, cmd)
+			}
+			return m, tea.Batch(cmds...)
+		}
+
+		// ctrl+c always quits, whatever quit is bound to
+		if msg.String() == "ctrl+c" {
 			return m, tea.Quit
-		case "j", "down":
-			m.viewport.LineDown(1)
-		case "k", "up":
-			m.viewport.LineUp(1)
-		case "g":
-			m.viewport.GotoTop()
-		case "G":
-			m.viewport.GotoBottom()
-		case "ctrl+d":
-			// m.viewport.LineDown(10)
-			m.viewport.HalfViewDown()
-		case "ctrl+u":
-			// m.viewport.LineUp(10)
-			m.viewport.HalfViewUp()
-		case "r": // Manually refresh
-			cmds = append(cmds, checkCmusCmd())
+		}
+
+		// Configurable keybindings, which can't use any of the fixed keys
+		if action, ok := m.keys[msg.String()]; ok {
+			if scrollActions[action] {
+				m.lastManualScroll = time.Now()
+			}
+
+			switch action {
+			case actionQuit:
+				return m, tea.Quit
+			case actionScrollDown:
+				m.viewport.LineDown(1)
+			case actionScrollUp:
+				m.viewport.LineUp(1)
+			case actionTop:
+				m.viewport.GotoTop()
+			case actionBottom:
+				m.viewport.GotoBottom()
+			case actionPageDown:
+				m.viewport.HalfViewDown()
+			case actionPageUp:
+				m.viewport.HalfViewUp()
+			case actionRefresh: // Manually refresh
+				m.refreshRequested = true
+				cmds = append(cmds, m.checkSongCmd())
+			}
+			break
+		}
+
+		switch msg.String() {
+		case "p": // Pause or resume polling cmus
+			m.pollingPaused = !m.pollingPaused
+		case "i": // Show the annotation for the line in the middle of the screen
+			cmds = append(cmds, m.toggleAnnotation())
+		case "y": // Copy the lyrics to the clipboard
+			cmds = append(cmds, m.copyLyrics())
+		case "s": // Save the lyrics to a file
+			cmds = append(cmds, m.saveCurrentLyrics())
+		case "/": // Search the lyrics
+			cmds = append(cmds, m.startSearchPrompt())
+		case "esc": // Clear the search, close the annotation or cancel the in-flight fetch
+			if m.search.active() {
+				m.clearSearch()
+			} else if m.showingAnnotation {
+				m.hideAnnotation()
+			} else if m.cancelFetch != nil {
+				m.cancelFetch()
+				m.cancelFetch = nil
+				// A cancelled refresh keeps the lyrics already shown
+				if m.lyricsSongID != generateSongID(m.artist, m.album, m.title) || m.state != "" {
+					m.showState(stateCancelled, "")
+				}
+			}
+		case "a": // Toggle scrolling along with playback
+			cmds = append(cmds, m.toggleAutoScroll())
+		case "b": // Toggle bold lyrics
+			m.boldLyrics = !m.boldLyrics
+			m.redraw()
+		case "#": // Toggle line numbers
+			m.showLineNumbers = !m.showLineNumbers
+			m.redraw()
+		case "A": // Correct the artist
+			cmds = append(cmds, m.startEdit("artist", m.artist))
+		case "T": // Correct the title
+			cmds = append(cmds, m.startEdit("title", m.title))
+		case "n": // Go to the next match
+			if m.search.active() {
+				cmds = append(cmds, m.jumpToMatch(1))
+			}
+		case "N": // Go to the previous match
+			if m.search.active() {
+				cmds = append(cmds, m.jumpToMatch(-1))
+			}
+		case "o": // Show the note for the current song
+			cmds = append(cmds, m.toggleNote())
+		case "O": // Edit the note for the current song
+			if m.artist != "" {
+				cmds = append(cmds, m.startEdit("note", m.notes[m.currentSongID]))
+			}
+		case "t": // Toggle between the playing and selected track
+			if m.enableSelectedTrack {
+				m.followSelected = !m.followSelected
+				cmds = append(cmds, m.checkSongCmd())
+			}
 		}
 
 	case tea.WindowSizeMsg:
@@ -74,50 +356,300 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		footerHeight := 1 // Help text
 		if !m.ready {
 			m.viewport = viewport.New(msg.Width, msg.Height-headerHeight-footerHeight)
-			m.viewport.SetContent(m.lyrics)
+
+			// Keys are handled through the keybindings, so the viewport's
+			// own would scroll a second time or clash with other keys
+			m.viewport.KeyMap = viewport.KeyMap{}
+			m.redraw()
 			m.ready = true
 		} else {
 			m.viewport.Width = msg.Width
 			m.viewport.Height = msg.Height - headerHeight - footerHeight
 
 			// Reflow lyrics if window size changes
-			m.updateLyrics(m.lyrics)
+			m.showingAnnotation = false
+			m.redraw()
 		}
 
 	case songInfoMsg:
+		// cmus briefly reports no song when moving between tracks, so keep
+		// showing the previous song until the gap has lasted a while
+		if msg.noSong && m.artist != "" && m.noSongGrace > 0 {
+			if m.noSongSince.IsZero() {
+				m.noSongSince = time.Now()
+			}
+			if remaining := m.noSongGrace - time.Since(m.noSongSince); remaining > 0 {
+				cmds = append(cmds, m.scheduleCheck(remaining))
+				break
+			}
+		}
+		m.noSongSince = time.Time{}
+
+		m.position = msg.position
+		m.duration = msg.duration
+		m.positionAt = time.Now()
+		m.currentSongID = generateSongID(msg.artist, msg.album, msg.title)
+
+		// Apply any manual correction made for this song
+		if o, ok := m.overrides[m.currentSongID]; ok {
+			msg.artist = o.artist
+			msg.title = o.title
+		}
+
 		// Only update if song changed
-		if m.artist != msg.artist || m.title != msg.title {
+		songChanged := m.artist != msg.artist || m.title != msg.title
+
+		// Switching between the library and a playlist, or pausing, doesn't
+		// change the song, so the status bar is updated separately
+		if msg.playSource != m.playSource {
+			m.playSource = msg.playSource
+			if !songChanged && m.showPlaySource {
+				m.updateStatusBar()
+			}
+		}
+		if msg.status != m.playbackStatus {
+			m.playbackStatus = msg.status
+			if !songChanged {
+				m.updateStatusBar()
+			}
+		}
+
+		if songChanged {
 			m.artist = msg.artist
 			m.album = msg.album
 			m.title = msg.title
+			m.albumArtist = msg.albumArtist
+			m.composer = msg.composer
 			m.updateStatusBar()
 
-			m.viewport.SetContent(m.centerText("Loading..."))
+			m.showingAnnotation = false
+			m.syncedLyrics = nil
+			m.search = searchState{}
+			if msg.noSong {
+				m.showState(stateNoSong, "")
+			} else if msg.artist == "" {
+				// cmus isn't running or the song can't be identified
+				m.showState(stateError, msg.title)
+			} else {
+				m.showState(stateLoading, "")
+			}
 
 			// Scroll back to top when song changes
 			m.viewport.GotoTop()
+
+			if m.songChangeCmd != "" && m.artist != "" {
+				cmds = append(cmds, runSongChangeHookCmd(m.songChangeCmd, m.artist, m.album, m.title))
+			}
+			if m.historyFile != "" && m.artist != "" {
+				cmds = append(cmds, appendHistoryCmd(m.historyFile, m.historyFormat, m.artist, m.album, m.title))
+			}
+			if m.artist != "" {
+				cmds = append(cmds, m.notifySongCmd(generateSongID(m.artist, m.album, m.title)))
+			}
 		}
 
-		// Schedule next check
-		cmds = append(cmds, tea.Tick(5*time.Second, func(t time.Time) tea.Msg {
-			return checkCmusTick{}
-		}))
+		// Schedule next check. When paused, polling can be stopped until the
+		// user presses a key, with only an occasional check in between.
+		m.pollingStopped = msg.status == statusPaused && m.pauseStopsPolling
+		if m.pollingStopped {
+			cmds = append(cmds, m.scheduleCheck(pausedPollInterval))
+		} else {
+			cmds = append(cmds, m.scheduleCheck(m.pollInterval))
+		}
 
-		// Schedule lyrics to be fetched asynchronously
-		cmds = append(cmds, fetchLyricsCmd(m.geniusAPIClient, m.artist, m.album, m.title))
+		// Schedule lyrics to be fetched asynchronously, unless there's no
+		// song to fetch them for. A refresh skips the cache. When skipping
+		// through songs, only look them up once the song has stayed the
+		// same for a moment; the first song and cached lyrics are fetched
+		// straight away.
+		if m.refreshRequested && m.artist != "" {
+			cmds = append(cmds, m.fetchLyrics(true))
+		} else if songChanged && m.artist != "" {
+			if m.fetchDebounce > 0 && m.lyricsSongID != "" && !m.lyricsProvider.isCached(m.searchArtist(), m.title) {
+				if m.cancelFetch != nil {
+					m.cancelFetch()
+					m.cancelFetch = nil
+				}
+				m.stopSyncedFetch()
+				m.stopAnnotationsFetch()
+				cmds = append(cmds, debounceFetchCmd(generateSongID(m.artist, m.album, m.title), m.fetchDebounce))
+			} else {
+				cmds = append(cmds, m.fetchLyrics(false))
+			}
+		}
+		m.refreshRequested = false
+
+	case debouncedFetchMsg:
+		// Only fetch if the song hasn't changed again in the meantime
+		if msg.songID == generateSongID(m.artist, m.album, m.title) {
+			cmds = append(cmds, m.fetchLyrics(false))
+		}
 
 	case songLyricsMsg:
+		// Ignore lyrics for a song that's no longer current. Fetches are
+		// cancelled on song change, but one may finish before noticing.
+		if msg.artist != m.artist || msg.title != m.title {
+			break
+		}
+
+		// The fetch for the current song is done
+		if m.cancelFetch != nil {
+			m.cancelFetch()
+			m.cancelFetch = nil
+		}
+
+		songID := generateSongID(msg.artist, msg.album, msg.title)
+		if errors.Is(msg.err, context.Canceled) {
+			// Cancelled by the user, who has already been told
+			break
+		}
+
+		m.debugStatus = formatScrapeStats(msg.scrapeStats) + ", " + m.lyricsProvider.Status()
+
+		// Synced lyrics are looked up once the lyrics are shown, and can
+		// stand in for them if no provider had plain lyrics
+		cmds = append(cmds, m.fetchSyncedLyrics())
+
+		if msg.err != nil {
+			if songID == m.lyricsSongID {
+				// Keep the lyrics we already have for this song and only
+				// surface the error in the footer
+				cmds = append(cmds, m.flashFooterMessage("Error: "+msg.err.Error()))
+			} else {
+				m.showState(stateError, msg.err.Error())
+			}
+		} else {
+			// Lyrics for the song already shown are a refresh, which keeps
+			// the reader's place unless configured otherwise
+			refreshed := songID == m.lyricsSongID
+			yOffset := m.viewport.YOffset
+
+			lyrics := msg.lyrics
+			if !m.showSectionHeaders {
+				lyrics = removeSectionHeaders(lyrics)
+			}
+			m.lyrics = truncateLyrics(lyrics, m.maxLyricsChars)
+			m.lyricsSongID = songID
+			m.syncedLyrics = nil
+			m.search = searchState{}
+			if strings.TrimSpace(m.lyrics) == "" {
+				m.showState(stateEmpty, "")
+			} else {
+				m.updateLyrics(m.lyrics)
+			}
+
+			if refreshed && m.refreshPreservesScroll {
+				m.viewport.SetYOffset(yOffset)
+			} else if refreshed {
+				m.viewport.GotoTop()
+			}
+		}
+
+	case syncedLyricsMsg:
+		// Ignore synced lyrics for a song that's no longer current
+		if msg.songID != generateSongID(m.artist, m.album, m.title) {
+			break
+		}
+		m.cancelSyncedFetch = nil
+		if len(msg.lines) == 0 {
+			break
+		}
+
+		// Without plain lyrics, show the text of the synced lyrics instead
+		if msg.songID != m.lyricsSongID || m.state == stateEmpty {
+			m.lyrics = truncateLyrics(lyricLinesText(msg.lines), m.maxLyricsChars)
+			m.lyricsSongID = msg.songID
+			m.search = searchState{}
+		}
+
+		// Synced lyrics highlight the line being sung as it plays
+		m.syncedLyrics = msg.lines
+		m.updateLyrics(m.lyrics)
+		cmds = append(cmds, m.schedulePlaybackTick())
+
+	case annotationsMsg:
+		// Ignore annotations for a song that's no longer shown
+		if msg.songID != generateSongID(m.artist, m.album, m.title) {
+			break
+		}
+		m.cancelAnnotationsFetch = nil
+
 		if msg.err != nil {
-			m.viewport.SetContent(msg.err.Error())
+			cmds = append(cmds, m.flashFooterMessage("Error fetching annotations: "+msg.err.Error()))
+			break
+		}
+
+		m.annotations = msg.annotations
+		m.annotationsSongID = msg.songID
+		cmds = append(cmds, m.showAnnotation(m.annotationLine))
+
+	case songChangeHookMsg:
+		if msg.err != nil {
+			cmds = append(cmds, m.flashFooterMessage("Error: "+msg.err.Error()))
+		}
+
+	case historyWrittenMsg:
+		if msg.err != nil {
+			cmds = append(cmds, m.flashFooterMessage("Error: "+msg.err.Error()))
+		}
+
+	case copiedMsg:
+		if msg.err != nil {
+			cmds = append(cmds, m.flashFooterMessage("Error copying lyrics: "+msg.err.Error()))
 		} else {
-			m.lyrics = msg.lyrics
-			m.updateLyrics(m.lyrics)
+			cmds = append(cmds, m.flashFooterMessage("Copied!"))
 		}
 
+	case lyricsSavedMsg:
+		if msg.err != nil {
+			cmds = append(cmds, m.flashFooterMessage("Error saving lyrics: "+msg.err.Error()))
+		} else {
+			cmds = append(cmds, m.flashFooterMessage("Saved to "+msg.path))
+		}
+
+	case notesSavedMsg:
+		if msg.err != nil {
+			cmds = append(cmds, m.flashFooterMessage("Error saving note: "+msg.err.Error()))
+		}
+
+	case clearFooterMessageMsg:
+		if !time.Now().Before(m.footerMessageExpiry) {
+			m.footerMessage = ""
+		}
+
+	case playbackTick:
+		// Only the most recently scheduled tick is acted on, so that
+		// toggling doesn't start additional loops
+		if msg.id != m.playbackTickID {
+			break
+		}
+		cmds = append(cmds, m.followPlayback())
+
+	case notifyTick:
+		cmds = append(cmds, m.notifySong(msg.songID))
+
+	case spinner.TickMsg:
+		cmds = append(cmds, m.updateSpinner(msg))
+
 	case checkCmusTick:
-		cmds = append(cmds, checkCmusCmd())
+		// Only the most recently scheduled tick is acted on, so that manual
+		// refreshes don't start additional polling loops
+		if msg.id != m.checkID {
+			break
+		}
+
+		// Keep ticking while paused so polling resumes when unpaused. The
+		// selected track isn't polled either, since reading it flashes the
+		// cmus command line; it's read when toggled to or refreshed.
+		if m.pollingPaused || m.followSelected {
+			cmds = append(cmds, m.scheduleCheck(m.pollInterval))
+			break
+		}
+		cmds = append(cmds, m.checkSongCmd())
 	}
 
+	cmds = append(cmds, m.startSpinner())
+
 	m.viewport, cmd = m.viewport.Update(msg)
 	cmds = append(cmds, cmd)
 
@@ -131,8 +663,8 @@ func (m model) View() string {
 	}
 
 	statusBarStyle := lipgloss.NewStyle().
-		Foreground(lipgloss.Color("#FFFFFF")).
-		Background(lipgloss.Color("#0088CC")).
+		Foreground(m.palette.statusBarFg).
+		Background(m.palette.statusBarBg).
 		Bold(true).
 		Width(m.viewport.Width).
 		Padding(0, 1)
@@ -146,38 +678,334 @@ func (m model) View() string {
 		scrollPercent = int(m.viewport.ScrollPercent() * 100)
 	}
 
-	var footer string
-	if m.showHelpFooter {
+	// The percentage is meaningless when everything fits on screen, so hide
+	// it in that case
+	rightText := fmt.Sprintf("%3d%%", scrollPercent)
+	if m.viewport.TotalLineCount() <= m.viewport.Height {
+		rightText = ""
+	}
+
+	// Indicate that the display is frozen
+	if m.pollingStopped {
+		rightText = strings.TrimSpace("[paused — polling stopped] " + rightText)
+	} else if m.pollingPaused {
+		rightText = strings.TrimSpace("[frozen] " + rightText)
+	}
+
+	// Pick the text shown to the left of the percentage. Transient
+	// messages take priority over debugging info, then the help text.
+	var leftText string
+	var leftStyle lipgloss.Style
+	if m.footerMessage != "" {
+		leftText = m.footerMessage
+		leftStyle = lipgloss.NewStyle().
+			Foreground(m.palette.errorFg)
+	} else if m.debug && m.debugStatus != "" {
+		leftText = m.debugStatus
+		leftStyle = lipgloss.NewStyle().
+			Foreground(m.palette.footer)
+	} else if m.showHelpFooter {
 		// Help text with keybindings
-		helpStyle := lipgloss.NewStyle().
-			Foreground(lipgloss.Color("#626262"))
+		leftText = m.helpText
+		leftStyle = lipgloss.NewStyle().
+			Foreground(m.palette.footer)
+	}
 
-		helpText := "j/k: scroll • g/G: top/bottom • C-d/C-u: page down/up • r: refresh • q: quit"
+	// The progress bar sits to the left of the percentage
+	bar := m.progressBarView()
+	rightWidth := lipgloss.Width(rightText)
+	if bar != "" {
+		rightWidth += lipgloss.Width(bar)
+		if rightText != "" {
+			rightWidth++
+		}
+	}
 
-		// Show both help text and percentage
-		percentStyle := lipgloss.NewStyle().
-			Foreground(lipgloss.Color("#626262")).
-			Bold(true)
+	// On narrow terminals switch to the compact help text, then drop the
+	// progress bar, and truncate whatever still doesn't fit so the
+	// percentage stays visible
+	available := m.viewport.Width - rightWidth - 1
+	if leftText == m.helpText && lipgloss.Width(leftText) > available {
+		leftText = m.compactHelpText
+	}
+	if bar != "" && leftText != "" && lipgloss.Width(leftText) > available {
+		available += lipgloss.Width(bar)
+		rightWidth -= lipgloss.Width(bar)
+		if rightText != "" {
+			available--
+			rightWidth--
+		}
+		bar = ""
+	}
+	if available <= 0 {
+		leftText = ""
+	} else if lipgloss.Width(leftText) > available {
+		leftText = truncate.StringWithTail(leftText, uint(available), "…")
+	}
+
+	percentStyle := lipgloss.NewStyle().
+		Foreground(m.palette.footer).
+		Bold(true)
+	right := percentStyle.Render(rightText)
+	if bar != "" && rightText != "" {
+		right = bar + " " + right
+	} else if bar != "" {
+		right = bar
+	}
 
-		// Join help text with percentage
+	var footer string
+	if m.editField != "" {
+		footer = m.editInput.View()
+	} else {
+		// The text goes on the left, and the bar and percentage on the
+		// right, with padding in between
 		footer = lipgloss.JoinHorizontal(
 			lipgloss.Left,
-			helpStyle.Render(helpText),
-			lipgloss.NewStyle().Width(m.viewport.Width-lipgloss.Width(helpText)-4).Render(""),
-			percentStyle.Render(fmt.Sprintf("%3d%%", scrollPercent)),
+			leftStyle.Render(leftText),
+			lipgloss.NewStyle().Width(m.viewport.Width-lipgloss.Width(leftText)-rightWidth).Render(""),
+			right,
 		)
+	}
+
+	return fmt.Sprintf("%s\n%s\n%s", statusBar, m.viewport.View(), footer)
+}
+
+// startEdit opens the footer input for correcting a field of the current
+// song, pre-filled with its current value
+func (m *model) startEdit(field, value string) tea.Cmd {
+	if m.currentSongID == "" {
+		return nil
+	}
+
+	m.editField = field
+	m.editInput = textinput.New()
+	m.editInput.Prompt = strings.ToUpper(field[:1]) + field[1:] + ": "
+	m.editInput.SetValue(value)
+	m.editInput.Focus()
+	return textinput.Blink
+}
+
+// applyEdit stores the edited field as an override for the current song and
+// fetches lyrics with the corrected info
+func (m *model) applyEdit() tea.Cmd {
+	value := strings.TrimSpace(m.editInput.Value())
+	field := m.editField
+	m.editField = ""
+	if field == "note" {
+		return m.applyNote(value)
+	}
+	if field == "search" {
+		return m.searchLyrics(value)
+	}
+	if value == "" {
+		return nil
+	}
+
+	o := songOverride{artist: m.artist, title: m.title}
+	if field == "artist" {
+		o.artist = value
 	} else {
-		// Only show percentage when help is hidden
-		percentStyle := lipgloss.NewStyle().
-			Foreground(lipgloss.Color("#626262")).
-			Bold(true).
-			Width(m.viewport.Width).
-			Align(lipgloss.Right)
+		o.title = value
+	}
+	m.overrides[m.currentSongID] = o
+
+	m.artist = o.artist
+	m.title = o.title
+	m.updateStatusBar()
+	m.showState(stateLoading, "")
+	m.viewport.GotoTop()
+
+	return m.fetchLyrics(false)
+}
 
-		footer = percentStyle.Render(fmt.Sprintf("%3d%%", scrollPercent))
+// searchArtist returns the artist to search for. Placeholder artists used on
+// compilations are replaced by the album artist or composer, or dropped
+// entirely so that only the title is searched.
+func (m *model) searchArtist() string {
+	for _, artist := range []string{m.artist, m.albumArtist, m.composer} {
+		if artist != "" && !m.isPlaceholderArtist(artist) {
+			return artist
+		}
 	}
+	return ""
+}
 
-	return fmt.Sprintf("%s\n%s\n%s", statusBar, m.viewport.View(), footer)
+// isPlaceholderArtist reports whether the artist is a placeholder such as
+// "Various Artists"
+func (m *model) isPlaceholderArtist(artist string) bool {
+	for _, placeholder := range m.placeholderArtists {
+		if strings.EqualFold(strings.TrimSpace(artist), placeholder) {
+			return true
+		}
+	}
+	return false
+}
+
+// nowPlayingText describes the current song for metadata-only mode
+func (m *model) nowPlayingText() string {
+	if m.artist == "" {
+		return m.title
+	}
+
+	lines := []string{"Now playing", "", m.title, m.artist}
+	if m.album != "" {
+		lines = append(lines, m.album)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// toggleAnnotation shows the annotation for the lyric line in the middle of
+// the viewport, fetching the song's annotations first if needed. If an
+// annotation is already shown, the lyrics are shown again instead.
+func (m *model) toggleAnnotation() tea.Cmd {
+	if m.showingAnnotation {
+		m.hideAnnotation()
+		return nil
+	}
+
+	// Only annotate lyrics that are actually on screen
+	songID := generateSongID(m.artist, m.album, m.title)
+	if songID != m.lyricsSongID {
+		return nil
+	}
+
+	line := m.middleLyricLine()
+	if m.annotationsSongID == songID {
+		return m.showAnnotation(line)
+	}
+
+	m.annotationLine = line
+	return tea.Batch(
+		m.flashFooterMessage("Loading annotations..."),
+		m.fetchAnnotations(),
+	)
+}
+
+// fetchAnnotations starts fetching the Genius annotations of the current
+// song, cancelling any annotation fetch that is already in flight
+func (m *model) fetchAnnotations() tea.Cmd {
+	m.stopAnnotationsFetch()
+	ctx, cancel := context.WithCancel(context.Background())
+	m.cancelAnnotationsFetch = cancel
+	songID := generateSongID(m.artist, m.album, m.title)
+	return fetchAnnotationsCmd(ctx, m.geniusAPIClient, songID, m.artist, m.album, m.title)
+}
+
+// stopAnnotationsFetch cancels the in-flight fetch of annotations, if any
+func (m *model) stopAnnotationsFetch() {
+	if m.cancelAnnotationsFetch != nil {
+		m.cancelAnnotationsFetch()
+		m.cancelAnnotationsFetch = nil
+	}
+}
+
+// middleLyricLine returns the text of the lyric line in the middle of the
+// viewport, or the nearest non-blank line to it
+func (m *model) middleLyricLine() string {
+	lines := strings.Split(m.viewport.View(), "\n")
+	gutterWidth := 0
+	if m.showLineNumbers {
+		gutterWidth = lineNumberGutterWidth(m.lyrics)
+	}
+
+	middle := len(lines) / 2
+	for distance := 0; distance <= middle+1; distance++ {
+		for _, i := range []int{middle - distance, middle + distance} {
+			if i < 0 || i >= len(lines) {
+				continue
+			}
+
+			// Strip styling and the line number gutter
+			line := []rune(ansiPattern.ReplaceAllString(lines[i], ""))
+			if len(line) < gutterWidth {
+				continue
+			}
+			if text := strings.TrimSpace(string(line[gutterWidth:])); text != "" {
+				return text
+			}
+		}
+	}
+	return ""
+}
+
+// showAnnotation shows the annotation whose fragment contains the given
+// lyric line in place of the lyrics
+func (m *model) showAnnotation(line string) tea.Cmd {
+	line = normalizeSongIDField(line)
+	if line == "" {
+		return nil
+	}
+
+	for _, a := range m.annotations {
+		fragment := normalizeSongIDField(a.Fragment)
+		if !strings.Contains(fragment, line) && !strings.Contains(line, fragment) {
+			continue
+		}
+
+		m.annotationYOffset = m.viewport.YOffset
+		m.showingAnnotation = true
+		m.viewport.SetContent(m.centerText(fmt.Sprintf("“%s”\n\n%s", a.Fragment, a.Body)))
+		m.viewport.GotoTop()
+		return nil
+	}
+
+	return m.flashFooterMessage("No annotation for this line")
+}
+
+// hideAnnotation shows the lyrics again at their previous scroll position
+func (m *model) hideAnnotation() {
+	m.showingAnnotation = false
+	m.updateLyrics(m.lyrics)
+	m.viewport.SetYOffset(m.annotationYOffset)
+}
+
+// fetchLyrics starts fetching lyrics for the current song, cancelling any
+// fetch that is already in flight. Cached lyrics are used unless bypassCache
+// is set. In metadata-only mode the song info is shown instead.
+func (m *model) fetchLyrics(bypassCache bool) tea.Cmd {
+	if m.metadataOnly {
+		m.lyrics = m.nowPlayingText()
+		m.updateLyrics(m.lyrics)
+		return nil
+	}
+
+	if m.cancelFetch != nil {
+		m.cancelFetch()
+	}
+	m.stopSyncedFetch()
+	m.stopAnnotationsFetch()
+
+	provider := m.lyricsProvider
+	if bypassCache {
+		provider = provider.forRefresh()
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	m.cancelFetch = cancel
+	return fetchLyricsCmd(ctx, provider, m.geniusAPIClient, m.artist, m.searchArtist(), m.album, m.title)
+}
+
+// fetchSyncedLyrics fetches the synced lyrics of the current song once its
+// lyrics have been fetched, so that they don't hold up showing the lyrics
+func (m *model) fetchSyncedLyrics() tea.Cmd {
+	if m.lyricsProvider == nil {
+		return nil
+	}
+
+	m.stopSyncedFetch()
+	ctx, cancel := context.WithCancel(context.Background())
+	m.cancelSyncedFetch = cancel
+	duration := time.Duration(m.duration) * time.Second
+	songID := generateSongID(m.artist, m.album, m.title)
+	return fetchSyncedLyricsCmd(ctx, m.lyricsProvider, songID, m.searchArtist(), m.album, m.title, duration)
+}
+
+// stopSyncedFetch cancels the in-flight fetch of synced lyrics, if any
+func (m *model) stopSyncedFetch() {
+	if m.cancelSyncedFetch != nil {
+		m.cancelSyncedFetch()
+		m.cancelSyncedFetch = nil
+	}
 }
 
 func (m *model) updateStatusBar() {
@@ -186,24 +1014,194 @@ func (m *model) updateStatusBar() {
 	} else {
 		m.statusBar = fmt.Sprintf("%s - %s", m.artist, m.title)
 	}
+
+	// Make it clear that we're not showing the playing track
+	if m.followSelected {
+		m.statusBar = "[Selected] " + m.statusBar
+	}
+
+	if m.showPlaySource && m.playSource != "" && m.artist != "" {
+		m.statusBar += " [from " + m.playSource + "]"
+	}
+
+	if _, ok := m.notes[m.currentSongID]; ok {
+		m.statusBar += " [note]"
+	}
+
+	m.statusBar = statusGlyph(m.playbackStatus) + " " + m.statusBar
+}
+
+// statusGlyph returns the symbol shown in the status bar for the playback
+// status
+func statusGlyph(status string) string {
+	switch status {
+	case statusPlaying:
+		return "▶"
+	case statusPaused:
+		return "⏸"
+	case statusStopped:
+		return "■"
+	default:
+		return "?"
+	}
 }
 
 func (m *model) updateLyrics(lyrics string) {
-	centeredLyrics := m.centerText(lyrics)
-	m.viewport.SetContent(centeredLyrics)
+	m.state = ""
+
+	key := renderCacheKey{
+		width:       m.viewport.Width,
+		lineNumbers: m.showLineNumbers,
+		bold:        m.boldLyrics,
+		highlight:   m.highlightedLine(),
+	}
+
+	// Height only affects the layout when centering vertically
+	if m.verticalCenter {
+		key.height = m.viewport.Height
+	}
+
+	if m.renderCache == nil {
+		m.renderCache = newRenderCache()
+	}
+	text := lyrics
+	if m.search.active() {
+		key.search = m.search.query
+		text = m.highlightMatches(lyrics)
+	}
+
+	if content, ok := m.renderCache.get(lyrics, key); ok {
+		m.viewport.SetContent(content)
+		return
+	}
+
+	var content string
+	if m.showLineNumbers {
+		content = m.numberText(text, key.highlight)
+	} else {
+		content = m.centerLyrics(text, key.highlight)
+	}
+
+	// Pad short lyrics so they sit in the middle of the viewport
+	content = strings.Repeat("\n", m.topPadding(lipgloss.Height(content))) + content
+
+	m.renderCache.put(lyrics, key, content)
+	m.viewport.SetContent(content)
+}
+
+// topPadding returns the number of blank rows above lyrics of the given
+// height, which are padded to the middle of the viewport when centering
+// vertically
+func (m *model) topPadding(height int) int {
+	if !m.verticalCenter || height >= m.viewport.Height {
+		return 0
+	}
+	return (m.viewport.Height - height) / 2
+}
+
+// formatScrapeStats describes the scrape stats for the debug footer. Lyrics
+// that weren't scraped, such as local files, have no stats.
+func formatScrapeStats(stats *ScrapeStats) string {
+	if stats == nil {
+		return "[debug] not scraped"
+	}
+	return fmt.Sprintf("[debug] html: %.1f KB, parse: %s",
+		float64(stats.HTMLSize)/1024, stats.ParseTime.Round(100*time.Microsecond))
+}
+
+// lineNumberGutterWidth returns the width of the line number gutter for the
+// text, including the space after the numbers
+func lineNumberGutterWidth(text string) int {
+	count := 0
+	for _, line := range strings.Split(text, "\n") {
+		if strings.TrimSpace(line) != "" {
+			count++
+		}
+	}
+	return len(strconv.Itoa(count)) + 1
+}
+
+// numberText centers each line in the space left of a line number gutter.
+// Blank lines aren't numbered. Lines are styled around the highlighted line,
+// see lyricStyle.
+func (m *model) numberText(text string, highlight int) string {
+	lines := strings.Split(text, "\n")
+	gutterWidth := lineNumberGutterWidth(text)
+	numberWidth := gutterWidth - 1
+
+	gutterStyle := lipgloss.NewStyle().
+		Foreground(m.palette.footer).
+		Width(gutterWidth)
+	lineStyle := lipgloss.NewStyle().
+		Width(m.viewport.Width - gutterWidth).
+		Align(m.alignment).
+		Bold(m.boldLyrics)
+
+	n := 0
+	numbered := make([]string, 0, len(lines))
+	for i, line := range lines {
+		// Blank lines are left empty, without a gutter or styling
+		if strings.TrimSpace(line) == "" {
+			numbered = append(numbered, "")
+			continue
+		}
+
+		n++
+		gutter := fmt.Sprintf("%*d", numberWidth, n)
+		numbered = append(numbered, trimTrailingSpaces(lipgloss.JoinHorizontal(
+			lipgloss.Top,
+			gutterStyle.Render(gutter),
+			m.lyricStyle(lineStyle, i, highlight).Render(line),
+		)))
+	}
+	return strings.Join(numbered, "\n")
+}
+
+// Lyrics alignments, see Config.Alignment
+const (
+	alignCenter = "center"
+	alignLeft   = "left"
+	alignRight  = "right"
+)
+
+// parseAlignment returns the lipgloss position for an alignment name
+func parseAlignment(name string) (lipgloss.Position, error) {
+	switch name {
+	case alignCenter:
+		return lipgloss.Center, nil
+	case alignLeft:
+		return lipgloss.Left, nil
+	case alignRight:
+		return lipgloss.Right, nil
+	}
+	return 0, errors.Errorf("%q must be center, left, or right", name)
 }
 
 func (m *model) centerText(text string) string {
+	return m.centerLyrics(text, -1)
+}
+
+// centerLyrics centers each line like centerText, styling lines around the
+// highlighted one, see lyricStyle
+func (m *model) centerLyrics(text string, highlight int) string {
 	// Center each line of the lyrics
 	centeredLyrics := ""
 	lines := strings.Split(text, "\n")
-	for _, line := range lines {
+	for i, line := range lines {
+		// Blank lines are left empty, as styling would pad them or add
+		// escape codes around nothing
+		if strings.TrimSpace(line) == "" {
+			centeredLyrics += "\n"
+			continue
+		}
+
 		// Use lipgloss to center each line within the viewport width
-		centeredLine := lipgloss.NewStyle().
+		style := lipgloss.NewStyle().
 			Width(m.viewport.Width).
-			Align(lipgloss.Center).
-			Render(line)
-		centeredLyrics += centeredLine + "\n"
+			Align(m.alignment).
+			Bold(m.boldLyrics)
+		centeredLine := m.lyricStyle(style, i, highlight).Render(line)
+		centeredLyrics += trimTrailingSpaces(centeredLine) + "\n"
 	}
 	// Remove trailing newline
 	if len(centeredLyrics) > 0 {
@@ -212,15 +1210,63 @@ func (m *model) centerText(text string) string {
 	return centeredLyrics
 }
 
+// trimTrailingSpaces removes the padding lipgloss adds to the right of each
+// line, so blank lines are truly empty and copied text has no trailing spaces
+func trimTrailingSpaces(text string) string {
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " ")
+	}
+	return strings.Join(lines, "\n")
+}
+
+// truncateLyrics cuts lyrics down to maxChars characters, appending a notice
+// with the original length. A maxChars of zero disables truncation.
+func truncateLyrics(lyrics string, maxChars int) string {
+	runes := []rune(lyrics)
+	if maxChars <= 0 || len(runes) <= maxChars {
+		return lyrics
+	}
+	return fmt.Sprintf("%s\n…(truncated, %d characters total)", string(runes[:maxChars]), len(runes))
+}
+
+// ansiPattern matches ANSI styling escape sequences
+var ansiPattern = regexp.MustCompile("\x1b\\[[0-9;]*m")
+
 // Message types for tea.Cmd
-type checkCmusTick time.Time
+type checkCmusTick struct {
+	id int
+}
+
+// annotationsMsg contains the fetched Genius annotations for a song
+type annotationsMsg struct {
+	songID      string
+	annotations []Annotation
+	err         error
+}
+
+// debouncedFetchMsg fetches lyrics for the song once it has been playing for
+// the debounce interval
+type debouncedFetchMsg struct {
+	songID string
+}
+
+// clearFooterMessageMsg clears the footer message once it has expired
+type clearFooterMessageMsg struct{}
 
 // songInfoMsg contains just the song metadata, without lyrics
 type songInfoMsg struct {
-	artist string
-	album  string
-	title  string
-	err    error
+	artist      string
+	album       string
+	title       string
+	albumArtist string
+	composer    string
+	playSource  string
+	noSong      bool
+	status      string
+	position    int
+	duration    int
+	err         error
 }
 
 // songLyricsMsg contains the song metadata and fetched lyrics
@@ -230,6 +1276,62 @@ type songLyricsMsg struct {
 	title  string
 	lyrics string
 	err    error
+
+	// Stats of the Genius scrape, or nil if the lyrics weren't scraped
+	scrapeStats *ScrapeStats
+}
+
+// syncedLyricsMsg contains the time-synced lyrics for a song, or nil if no
+// provider has them
+type syncedLyricsMsg struct {
+	songID string
+	lines  []LyricLine
+}
+
+// cmusTag returns the value of the given tag from cmus-remote -Q output
+func cmusTag(output, tag string) string {
+	prefix := "tag " + tag + " "
+	for _, line := range strings.Split(output, "\n") {
+		if strings.HasPrefix(line, prefix) {
+			return strings.TrimPrefix(line, prefix)
+		}
+	}
+	return ""
+}
+
+// cmusPlaySource returns whether cmus is playing from the "library" or a
+// "playlist", based on its play_library setting in cmus-remote -Q output. It
+// returns an empty string if the setting isn't reported.
+func cmusPlaySource(output string) string {
+	for _, line := range strings.Split(output, "\n") {
+		switch strings.TrimSpace(line) {
+		case "set play_library true":
+			return "library"
+		case "set play_library false":
+			return "playlist"
+		}
+	}
+	return ""
+}
+
+// parseCmusProgress returns the playback position and duration of the song in
+// seconds from cmus-remote -Q output. Either is zero if it isn't reported,
+// such as for streams without a duration.
+func parseCmusProgress(output string) (position, duration int) {
+	for _, line := range strings.Split(output, "\n") {
+		key, value, ok := strings.Cut(strings.TrimSpace(line), " ")
+		if !ok {
+			continue
+		}
+		switch key {
+		case "position":
+			position, _ = strconv.Atoi(value)
+		case "duration":
+			duration, _ = strconv.Atoi(value)
+		}
+	}
+	// Streams are reported with a duration of -1
+	return max(position, 0), max(duration, 0)
 }
 
 // Extract information from cmus-remote -Q output
@@ -247,16 +1349,39 @@ func parseCmusOutput(output string) (artist, album, title string) {
 	return
 }
 
-// generateSongID creates a unique identifier for a song
-func generateSongID(artist, title string) string {
-	return fmt.Sprintf("%s-%s", strings.ToLower(artist), strings.ToLower(title))
+// songIDSeparator joins the fields of a song ID. A control character is used
+// since it won't appear in tags, unlike a dash.
+const songIDSeparator = "\x1f"
+
+// generateSongID creates a unique identifier for a song. The album is
+// included when present so that different versions of a song with the same
+// artist and title don't collide.
+func generateSongID(artist, album, title string) string {
+	fields := []string{normalizeSongIDField(artist), normalizeSongIDField(title)}
+	if album != "" {
+		fields = []string{fields[0], normalizeSongIDField(album), fields[1]}
+	}
+	return strings.Join(fields, songIDSeparator)
+}
+
+// normalizeSongIDField lowercases a tag and collapses its whitespace, so that
+// stray spaces in tags don't produce different IDs
+func normalizeSongIDField(s string) string {
+	return strings.Join(strings.Fields(strings.ToLower(s)), " ")
 }
 
-// fetchLyricsCmd is a command to fetch lyrics asynchronously
-func fetchLyricsCmd(client *GeniusAPIClient, artist, album, title string) tea.Cmd {
+// fetchLyricsCmd is a command to fetch lyrics asynchronously. searchArtist is
+// the artist used for the lookup, which may differ from the tagged artist.
+// The Genius client is only used for its scrape stats. Synced lyrics are
+// looked up separately by fetchSyncedLyricsCmd once these are shown.
+func fetchLyricsCmd(ctx context.Context, provider LyricsProvider, client *GeniusAPIClient, artist, searchArtist, album, title string) tea.Cmd {
 	return func() tea.Msg {
-		ctx := context.Background()
-		lyrics, err := client.GetLyrics(ctx, artist, title)
+		// The scrape stats only change if this fetch scraped Genius. A
+		// concurrent fetch may be attributed here, which is fine for
+		// debugging.
+		statsBefore := client.LastScrapeStats()
+
+		lyrics, err := provider.GetLyrics(ctx, searchArtist, album, title)
 		if err != nil {
 			return songLyricsMsg{
 				artist: artist,
@@ -267,21 +1392,139 @@ func fetchLyricsCmd(client *GeniusAPIClient, artist, album, title string) tea.Cm
 			}
 		}
 
-		return songLyricsMsg{
+		msg := songLyricsMsg{
 			artist: artist,
 			album:  album,
 			title:  title,
 			lyrics: lyrics,
 			err:    nil,
 		}
+		if stats := client.LastScrapeStats(); stats != statsBefore {
+			msg.scrapeStats = &stats
+		}
+		return msg
+	}
+}
+
+// fetchSyncedLyricsCmd is a command to fetch the time-synced lyrics for the
+// song. Synced lyrics are optional, so failing to find them is ignored.
+func fetchSyncedLyricsCmd(ctx context.Context, provider SyncedLyricsProvider, songID, searchArtist, album, title string, duration time.Duration) tea.Cmd {
+	return func() tea.Msg {
+		lines, _ := provider.GetSyncedLyrics(ctx, searchArtist, album, title, duration)
+		return syncedLyricsMsg{songID: songID, lines: lines}
+	}
+}
+
+// parseTrackFilename derives song info from a track's file path, for when its
+// tags can't be read. Files named "Artist - Title.ext" are split on the
+// separator; otherwise the file name is used as the title and the parent
+// directory as the artist.
+func parseTrackFilename(path string) (artist, album, title string) {
+	base := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	dir := filepath.Base(filepath.Dir(path))
+
+	if parts := strings.SplitN(base, " - ", 2); len(parts) == 2 {
+		return strings.TrimSpace(parts[0]), dir, strings.TrimSpace(parts[1])
+	}
+	return dir, "", strings.TrimSpace(base)
+}
+
+// debounceFetchCmd is a command to fetch lyrics for the song after the
+// interval, if it's still current by then
+func debounceFetchCmd(songID string, interval time.Duration) tea.Cmd {
+	return tea.Tick(interval, func(time.Time) tea.Msg {
+		return debouncedFetchMsg{songID: songID}
+	})
+}
+
+// checkSongCmd checks the player for the current song, or cmus for the
+// selected track if following it
+func (m *model) checkSongCmd() tea.Cmd {
+	if m.followSelected {
+		return checkSelectedTrackCmd()
+	}
+	return checkPlayerCmd(m.player)
+}
+
+// scheduleCheck schedules cmus to be checked after the given delay,
+// superseding any check scheduled earlier
+func (m *model) scheduleCheck(d time.Duration) tea.Cmd {
+	m.checkID++
+	id := m.checkID
+	return tea.Tick(d, func(t time.Time) tea.Msg {
+		return checkCmusTick{id: id}
+	})
+}
+
+// flashFooterMessage shows a transient message in the footer
+func (m *model) flashFooterMessage(text string) tea.Cmd {
+	m.footerMessage = text
+	m.footerMessageExpiry = time.Now().Add(footerMessageDuration)
+	return clearFooterMessageCmd()
+}
+
+// clearFooterMessageCmd schedules the footer message to be cleared
+func clearFooterMessageCmd() tea.Cmd {
+	return tea.Tick(footerMessageDuration, func(t time.Time) tea.Msg {
+		return clearFooterMessageMsg{}
+	})
+}
+
+// fetchAnnotationsCmd is a command to fetch Genius annotations asynchronously
+func fetchAnnotationsCmd(ctx context.Context, client *GeniusAPIClient, songID, artist, album, title string) tea.Cmd {
+	return func() tea.Msg {
+		annotations, err := client.GetAnnotations(ctx, artist, album, title)
+		return annotationsMsg{
+			songID:      songID,
+			annotations: annotations,
+			err:         err,
+		}
+	}
+}
+
+// readTrackTags reads the artist, album and title tags of the file with
+// ffprobe, as cmus only reports the file name of the selected track
+func readTrackTags(path string) (artist, album, title string, err error) {
+	cmd := exec.Command("ffprobe", "-v", "quiet",
+		"-show_entries", "format_tags=artist,album,title",
+		"-of", "default=noprint_wrappers=1", path)
+	output, err := cmd.Output()
+	if err != nil {
+		return "", "", "", errors.Wrap(err, "ffprobe")
+	}
+	artist, album, title = parseFFprobeTags(string(output))
+	return artist, album, title, nil
+}
+
+// parseFFprobeTags extracts the tags from ffprobe output, which has a
+// "TAG:name=value" line for each tag. Tag names are matched ignoring case,
+// since formats differ in how they're stored.
+func parseFFprobeTags(output string) (artist, album, title string) {
+	for _, line := range strings.Split(output, "\n") {
+		key, value, ok := strings.Cut(strings.TrimSpace(line), "=")
+		if !ok {
+			continue
+		}
+		switch strings.ToLower(strings.TrimPrefix(key, "TAG:")) {
+		case "artist":
+			artist = value
+		case "album":
+			album = value
+		case "title":
+			title = value
+		}
 	}
+	return
 }
 
-// checkCmusCmd checks cmus status and updates the song info if changed
-func checkCmusCmd() tea.Cmd {
+// checkSelectedTrackCmd gets the song info of the track selected in cmus,
+// which may differ from the one that is playing. Its tags are read with
+// ffprobe if it's installed, falling back to parsing the file name. It isn't
+// polled, as cmus briefly shows the command on its command line.
+func checkSelectedTrackCmd() tea.Cmd {
 	return func() tea.Msg {
-		// Run cmus-remote -Q to get current song information
-		cmd := exec.Command("cmus-remote", "-Q")
+		// cmus replaces {} with the file name of the selected track
+		cmd := exec.Command("cmus-remote", "-C", "echo {}")
 		output, err := cmd.CombinedOutput()
 		if err != nil {
 			return songInfoMsg{
@@ -292,30 +1535,20 @@ func checkCmusCmd() tea.Cmd {
 			}
 		}
 
-		// Check if cmus is playing something
-		outputStr := string(output)
-		if !regexp.MustCompile(`status (playing|paused)`).MatchString(outputStr) {
+		path := strings.TrimSpace(string(output))
+		if path == "" || path == "{}" {
 			return songInfoMsg{
 				artist: "",
 				album:  "",
-				title:  "No song playing",
+				title:  "No track selected",
 				err:    nil,
 			}
 		}
 
-		// Parse the output to get song info
-		artist, album, title := parseCmusOutput(outputStr)
-
-		if artist == "" || title == "" {
-			return songInfoMsg{
-				artist: "",
-				album:  "",
-				title:  "Unknown song",
-				err:    fmt.Errorf("missing artist or title information"),
-			}
+		artist, album, title, err := readTrackTags(path)
+		if err != nil || artist == "" || title == "" {
+			artist, album, title = parseTrackFilename(path)
 		}
-
-		// Return the song info without fetching lyrics yet
 		return songInfoMsg{
 			artist: artist,
 			album:  album,
@@ -330,20 +1563,40 @@ func printUsage() {
 
 Usage:
   lyrics <command> [arguments]
+  lyrics <query>
 
 Commands:
   cmus              Launch interactive TUI with cmus integration
   query <query>     Fetch lyrics for a query and print to stdout
   q <query>         Shorthand for 'query'
+  doctor            Check that the environment is set up correctly
+  set-token         Store the Genius access token in the system keyring
 
 Flags (for cmus command):
   --show-help-footer    Show keybinding help text in the footer
+  --fifo <path>         Write "Artist - Title" to a FIFO on each song change
+                        instead of running the TUI
+  --metadata-only       Show only the current song without fetching lyrics
+  --notify              Show a desktop notification with the first lyrics on
+                        each song change (requires notify-send or osascript)
+  --debug               Show the scraped page size, parse time and provider
+                        health in the footer
+  --player <name>       Player to follow: cmus, mpd or playerctl (default
+                        from config)
+  --align <alignment>   Align the lyrics center, left or right (default
+                        from config)
+
+Flags (for doctor command):
+  --network             Validate the Genius access token against the API
 
 Examples:
   lyrics cmus
   lyrics cmus --show-help-footer
+  lyrics cmus --fifo /tmp/lyrics.fifo
   lyrics query "black sabbath paranoid"
   lyrics q "artist song title"
+  lyrics "artist song title"
+  lyrics doctor --network
 `
 	fmt.Print(usage)
 }
@@ -352,18 +1605,112 @@ func runCmusCommand(config Config, args []string) {
 	// Create FlagSet for cmus-specific flags
 	cmusFlags := flag.NewFlagSet("cmus", flag.ExitOnError)
 	showHelpFooter := cmusFlags.Bool("show-help-footer", false, "Show keybinding help text in the footer")
+	fifoPath := cmusFlags.String("fifo", "", "Write the current song to this FIFO instead of running the TUI")
+	metadataOnly := cmusFlags.Bool("metadata-only", false, "Show only the current song without fetching lyrics")
+	notify := cmusFlags.Bool("notify", false, "Show a desktop notification with the lyrics on each song change")
+	debug := cmusFlags.Bool("debug", false, "Show the scraped page size, parse time and provider health in the footer")
+	playerName := cmusFlags.String("player", config.Player, "Player to follow: cmus, mpd or playerctl")
+	align := cmusFlags.String("align", config.Alignment, "Align the lyrics center, left or right")
 
 	if err := cmusFlags.Parse(args); err != nil {
 		log.Fatal(err)
 	}
 
-	geniusAPIClient := NewGeniusAPIClient(config.GeniusAccessToken)
+	player, err := newPlayer(*playerName)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	alignment, err := parseAlignment(*align)
+	if err != nil {
+		log.Fatal(errors.Wrap(err, "invalid --align"))
+	}
+
+	// The keybindings were validated when the config was loaded
+	bindings, err := resolveKeybindings(config.Keybindings)
+	if err != nil {
+		log.Fatal(err)
+	}
+	helpText, compactHelpText := buildHelpText(bindings)
+
+	if *fifoPath != "" {
+		if err := ensureFIFO(*fifoPath); err != nil {
+			log.Fatal(err)
+		}
+		ctx, cancel := signalContext()
+		defer cancel()
+		runFIFOMode(ctx, player, *fifoPath, time.Duration(config.PollIntervalSeconds)*time.Second)
+		return
+	}
+
+	geniusAPIClient := newGeniusAPIClient(config)
+
+	// Notifications are a no-op when no notifier is installed
+	var notifier string
+	if *notify {
+		notifier = findNotifier()
+	}
+
+	// Notes that can't be read are fatal, as saving would overwrite them
+	notesFile, err := getNotesPath()
+	if err != nil {
+		log.Fatal(err)
+	}
+	notes, err := loadNotes(notesFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Show a placeholder until the first song is detected, unless the user
+	// prefers a blank screen
+	initialText, initialState := "Loading...", stateLoading
+	if config.BlankInitialState {
+		initialText, initialState = "", ""
+	}
+
+	colors := config.Theme.apply(selectPalette(config.ColorScheme))
 
 	initialModel := model{
-		statusBar:       "Loading...",
-		lyrics:          "Loading...",
+		statusBar:       initialText,
+		state:           initialState,
+		stateStyle:      newStateStyle(config.StateMessages, config.StateColors, colors),
+		spinner:         newLoadingSpinner(),
+		progress:        newProgressBar(colors),
 		showHelpFooter:  *showHelpFooter,
+		keys:            keyActions(bindings),
+		metadataOnly:    *metadataOnly,
+		player:          player,
 		geniusAPIClient: geniusAPIClient,
+		lyricsProvider:  newLyricsProvider(config, geniusAPIClient),
+		palette:         colors,
+
+		enableSelectedTrack: config.EnableSelectedTrack && *playerName == playerCmus,
+		maxLyricsChars:      config.MaxLyricsChars,
+		noSongGrace:         time.Duration(config.NoSongGraceSeconds) * time.Second,
+		showLineNumbers:     config.ShowLineNumbers,
+		verticalCenter:      config.VerticalCenter,
+		boldLyrics:          config.BoldLyrics,
+		alignment:           alignment,
+		songChangeCmd:       config.SongChangeCmd,
+		placeholderArtists:  config.PlaceholderArtists,
+		pauseStopsPolling:   config.PauseStopsPolling,
+		historyFile:         config.HistoryFile,
+		historyFormat:       config.HistoryFormat,
+		notifier:            notifier,
+		clipboard:           findClipboard(),
+		savePathTemplate:    config.SavePathTemplate,
+		overrides:           make(map[string]songOverride),
+		showPlaySource:      config.ShowPlaySource,
+		showSectionHeaders:  config.ShowSectionHeaders,
+		fetchDebounce:       time.Duration(config.FetchDebounceMillis) * time.Millisecond,
+		pollInterval:        time.Duration(config.PollIntervalSeconds) * time.Second,
+		notes:               notes,
+		notesFile:           notesFile,
+
+		refreshPreservesScroll: config.RefreshPreservesScroll,
+		helpText:               helpText,
+		compactHelpText:        compactHelpText,
+		debug:                  *debug,
 	}
 
 	p := tea.NewProgram(initialModel, tea.WithAltScreen())
@@ -390,38 +1737,72 @@ func runQueryCommand(config Config, args []string) {
 
 	query := strings.Join(remainingArgs, " ")
 
-	geniusAPIClient := NewGeniusAPIClient(config.GeniusAccessToken)
+	if config.GeniusAccessToken == "" && config.needsAccessToken() {
+		fmt.Fprintln(os.Stderr, "Error: "+errNoAccessToken.Error())
+		os.Exit(1)
+	}
+
+	provider := newLyricsProvider(config, newGeniusAPIClient(config))
 
-	lyrics, err := geniusAPIClient.GetLyrics(context.Background(), query, "")
+	ctx, cancel := signalContext()
+	defer cancel()
+
+	lyrics, err := provider.GetLyrics(ctx, query, "", "")
+	if errors.Is(err, context.Canceled) {
+		// Interrupted, so exit quietly with the conventional status
+		os.Exit(130)
+	}
 	if err != nil {
 		log.Fatal(err)
 	}
-	fmt.Println(lyrics)
+	if !config.ShowSectionHeaders {
+		lyrics = removeSectionHeaders(lyrics)
+	}
+	fmt.Println(truncateLyrics(lyrics, config.MaxLyricsChars))
 }
 
 func main() {
-	// Load configuration
-	config, err := LoadConfig()
-	if err != nil {
-		log.Fatal(err)
-	}
-
 	// Check for subcommand
 	if len(os.Args) < 2 {
 		printUsage()
 		os.Exit(0)
 	}
 
-	// Route to command
 	cmdName := os.Args[1]
 	cmdArgs := os.Args[2:]
 
+	// The doctor command diagnoses config problems, so it must run before
+	// the config is loaded
+	if cmdName == "doctor" {
+		runDoctorCommand(cmdArgs)
+		return
+	}
+
+	// Storing the token doesn't need the config either
+	if cmdName == "set-token" || cmdName == "--set-token" {
+		runSetTokenCommand()
+		return
+	}
+
+	// Load configuration
+	config, err := LoadConfig()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Route to command
 	switch cmdName {
 	case "cmus":
 		runCmusCommand(config, cmdArgs)
 	case "query", "q":
 		runQueryCommand(config, cmdArgs)
 	default:
+		// Anything that isn't a command or flag is shorthand for a query,
+		// e.g. lyrics "artist song title"
+		if !strings.HasPrefix(cmdName, "-") {
+			runQueryCommand(config, os.Args[1:])
+			return
+		}
 		fmt.Fprintf(os.Stderr, "Error: unknown command %q\n\n", cmdName)
 		printUsage()
 		os.Exit(1)
@@ -0,0 +1,455 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/muesli/termenv"
+)
+
+// newTestModel returns a model sized to the given terminal, as it is once
+// the program has started
+func newTestModel(width, height int) model {
+	m := model{
+		stateStyle: newStateStyle(nil, nil, darkPalette),
+		spinner:    newLoadingSpinner(),
+		progress:   newProgressBar(darkPalette),
+		palette:    darkPalette,
+		keys:       keyActions(defaultKeybindings),
+		alignment:  lipgloss.Center,
+		overrides:  make(map[string]songOverride),
+
+		lyricsProvider: NewChainProvider(nil),
+	}
+	updated, _ := m.Update(tea.WindowSizeMsg{Width: width, Height: height})
+	return updated.(model)
+}
+
+// update sends the message to the model and returns the updated model
+func update(t *testing.T, m model, msg tea.Msg) model {
+	t.Helper()
+	updated, _ := m.Update(msg)
+	return updated.(model)
+}
+
+// withSong returns the model showing the song as playing, with its lyrics
+// being fetched
+func withSong(m model, artist, album, title string) model {
+	m.artist, m.album, m.title = artist, album, title
+	m.currentSongID = generateSongID(artist, album, title)
+	m.showState(stateLoading, "")
+	return m
+}
+
+// fakeProvider returns fixed lyrics and counts the synced lyrics lookups
+type fakeProvider struct {
+	lyrics      string
+	err         error
+	synced      []LyricLine
+	syncedCalls atomic.Int32
+}
+
+func (p *fakeProvider) GetLyrics(ctx context.Context, artist, album, title string) (string, error) {
+	return p.lyrics, p.err
+}
+
+func (p *fakeProvider) GetSyncedLyrics(ctx context.Context, artist, album, title string, duration time.Duration) ([]LyricLine, error) {
+	p.syncedCalls.Add(1)
+	if p.synced == nil {
+		return nil, &NotFoundError{}
+	}
+	return p.synced, nil
+}
+
+func TestFetchLyricsCmdDoesNotWaitForSyncedLyrics(t *testing.T) {
+	provider := &fakeProvider{lyrics: "First line"}
+	client := NewGeniusAPIClient("", GeniusAPIClientOptions{})
+
+	msg := fetchLyricsCmd(context.Background(), provider, client, "Artist", "Artist", "Album", "Title")()
+	if got := msg.(songLyricsMsg).lyrics; got != "First line" {
+		t.Errorf("lyrics = %q, want %q", got, "First line")
+	}
+	if calls := provider.syncedCalls.Load(); calls != 0 {
+		t.Errorf("synced lyrics looked up %d times while fetching lyrics", calls)
+	}
+}
+
+func TestSyncedLyricsFollowLyrics(t *testing.T) {
+	synced := []LyricLine{{Time: time.Second, Text: "First line"}, {Time: 2 * time.Second, Text: "Second line"}}
+
+	tests := []struct {
+		name       string
+		lyricsMsg  songLyricsMsg
+		wantLyrics string
+	}{
+		{
+			name:       "plain lyrics are kept",
+			lyricsMsg:  songLyricsMsg{artist: "Artist", album: "Album", title: "Title", lyrics: "First line\nSecond line"},
+			wantLyrics: "First line\nSecond line",
+		},
+		{
+			name:       "synced lyrics stand in when no provider had plain lyrics",
+			lyricsMsg:  songLyricsMsg{artist: "Artist", album: "Album", title: "Title", err: &NotFoundError{}},
+			wantLyrics: "First line\nSecond line",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider := &fakeProvider{synced: synced}
+			m := withSong(newTestModel(40, 10), "Artist", "Album", "Title")
+			m.lyricsProvider = NewChainProvider(nil)
+			m.lyricsProvider.AddLocal("fake", provider)
+
+			updated, cmd := m.Update(tt.lyricsMsg)
+			m = updated.(model)
+			if cmd == nil {
+				t.Fatal("no command to fetch the synced lyrics")
+			}
+			if tt.lyricsMsg.err == nil && m.state != "" {
+				t.Errorf("state = %q, want the lyrics shown straight away", m.state)
+			}
+
+			songID := generateSongID("Artist", "Album", "Title")
+			msg := fetchSyncedLyricsCmd(context.Background(), m.lyricsProvider, songID, "Artist", "Album", "Title", 0)()
+			m = update(t, m, msg)
+			if m.lyrics != tt.wantLyrics {
+				t.Errorf("lyrics = %q, want %q", m.lyrics, tt.wantLyrics)
+			}
+			if len(m.syncedLyrics) != len(synced) {
+				t.Errorf("got %d synced lines, want %d", len(m.syncedLyrics), len(synced))
+			}
+		})
+	}
+}
+
+func TestSyncedLyricsForPreviousSongIgnored(t *testing.T) {
+	m := withSong(newTestModel(40, 10), "Artist", "Album", "Title")
+	m = update(t, m, syncedLyricsMsg{
+		songID: generateSongID("Other", "Album", "Song"),
+		lines:  []LyricLine{{Text: "Wrong song"}},
+	})
+	if m.syncedLyrics != nil || strings.Contains(m.lyrics, "Wrong song") {
+		t.Error("synced lyrics for another song were shown")
+	}
+}
+
+func TestRapidSongChangesShowLatestLyrics(t *testing.T) {
+	m := newTestModel(40, 10)
+	m.lyricsProvider = NewChainProvider(nil)
+	m.geniusAPIClient = NewGeniusAPIClient("", GeniusAPIClientOptions{})
+
+	songs := []songInfoMsg{
+		{artist: "First", title: "Song", status: statusPlaying},
+		{artist: "Second", title: "Song", status: statusPlaying},
+		{artist: "Third", title: "Song", status: statusPlaying},
+	}
+
+	cancelled := 0
+	for i, song := range songs {
+		m = update(t, m, song)
+		if m.cancelFetch == nil {
+			t.Fatalf("no fetch started for song %d", i)
+		}
+		if i < len(songs)-1 {
+			m.cancelFetch = func() { cancelled++ }
+		}
+	}
+	if cancelled != len(songs)-1 {
+		t.Errorf("%d fetches cancelled, want %d", cancelled, len(songs)-1)
+	}
+
+	// Fetches for the earlier songs may still finish, in any order
+	m = update(t, m, songLyricsMsg{artist: "Second", title: "Song", lyrics: "Second lyrics"})
+	m = update(t, m, songLyricsMsg{artist: "Third", title: "Song", lyrics: "Third lyrics"})
+	m = update(t, m, songLyricsMsg{artist: "First", title: "Song", lyrics: "First lyrics"})
+
+	if m.lyrics != "Third lyrics" {
+		t.Errorf("lyrics = %q, want those of the latest song", m.lyrics)
+	}
+	if view := m.viewport.View(); !strings.Contains(view, "Third lyrics") {
+		t.Errorf("viewport = %q, want the latest song's lyrics", view)
+	}
+}
+
+func TestStaleLyricsDoNotReplaceLoading(t *testing.T) {
+	m := withSong(newTestModel(40, 10), "Second", "", "Song")
+	m = update(t, m, songLyricsMsg{artist: "First", title: "Song", lyrics: "First lyrics"})
+	if m.state != stateLoading {
+		t.Errorf("state = %q, want %q while the current song's lyrics load", m.state, stateLoading)
+	}
+}
+
+func TestGenerateSongIDNormalization(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b [3]string
+		same bool
+	}{
+		{"case", [3]string{"Radiohead", "", "Creep"}, [3]string{"RADIOHEAD", "", "creep"}, true},
+		{"unicode case", [3]string{"Sigur Rós", "", "Ágætis Byrjun"}, [3]string{"SIGUR RÓS", "", "ÁGÆTIS BYRJUN"}, true},
+		{"trailing spaces", [3]string{"Radiohead ", "", " Creep"}, [3]string{"Radiohead", "", "Creep"}, true},
+		{"repeated spaces", [3]string{"The  Beatles", "", "Hey\tJude"}, [3]string{"The Beatles", "", "Hey Jude"}, true},
+		{"dash in artist or title", [3]string{"Jay-Z", "", "Song"}, [3]string{"Jay", "", "Z-Song"}, false},
+		{"dash separator lookalike", [3]string{"A - B", "", "C"}, [3]string{"A", "", "B - C"}, false},
+		{"different title", [3]string{"Radiohead", "", "Creep"}, [3]string{"Radiohead", "", "Karma Police"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := generateSongID(tt.a[0], tt.a[1], tt.a[2])
+			b := generateSongID(tt.b[0], tt.b[1], tt.b[2])
+			if (a == b) != tt.same {
+				t.Errorf("generateSongID(%q) = %q and generateSongID(%q) = %q, want equal %v", tt.a, a, tt.b, b, tt.same)
+			}
+		})
+	}
+}
+
+func TestGenerateSongIDAlbumCollisions(t *testing.T) {
+	studio := generateSongID("Radiohead", "Pablo Honey", "Creep")
+	acoustic := generateSongID("Radiohead", "My Iron Lung", "Creep")
+	noAlbum := generateSongID("Radiohead", "", "Creep")
+
+	tests := []struct {
+		name string
+		a, b string
+		same bool
+	}{
+		{"same song on different albums", studio, acoustic, false},
+		{"song with and without an album", studio, noAlbum, false},
+		{"album case and spacing", studio, generateSongID("radiohead", " PABLO  HONEY ", "creep"), true},
+		{"album isn't confused with the title", generateSongID("Artist", "Title", "Album"), generateSongID("Artist", "Album", "Title"), false},
+		{"album isn't confused with the artist", generateSongID("A", "B", "C"), generateSongID("A B", "", "C"), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if (tt.a == tt.b) != tt.same {
+				t.Errorf("IDs %q and %q, want equal %v", tt.a, tt.b, tt.same)
+			}
+		})
+	}
+
+	// IDs without an album keep the artist and title format
+	if want := "radiohead" + songIDSeparator + "creep"; noAlbum != want {
+		t.Errorf("generateSongID without an album = %q, want %q", noAlbum, want)
+	}
+}
+
+// withColorProfile renders with escape codes for the duration of the test,
+// as on a color terminal
+func withColorProfile(t *testing.T) {
+	profile := lipgloss.ColorProfile()
+	lipgloss.SetColorProfile(termenv.ANSI256)
+	t.Cleanup(func() { lipgloss.SetColorProfile(profile) })
+}
+
+func TestRenderedLyricsHaveNoTrailingWhitespace(t *testing.T) {
+	withColorProfile(t)
+	lyrics := "[Verse 1]\nFirst line\n\n  \nSecond line \n\n[Chorus]\nLast line"
+
+	tests := []struct {
+		name        string
+		bold        bool
+		lineNumbers bool
+		alignment   lipgloss.Position
+		highlight   int
+	}{
+		{name: "centered", alignment: lipgloss.Center, highlight: -1},
+		{name: "bold", bold: true, alignment: lipgloss.Center, highlight: -1},
+		{name: "highlighted", alignment: lipgloss.Center, highlight: 1},
+		{name: "left aligned", alignment: lipgloss.Left, highlight: -1},
+		{name: "right aligned", alignment: lipgloss.Right, highlight: -1},
+		{name: "line numbers", lineNumbers: true, alignment: lipgloss.Center, highlight: -1},
+		{name: "bold line numbers", bold: true, lineNumbers: true, alignment: lipgloss.Center, highlight: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newTestModel(40, 20)
+			m.boldLyrics = tt.bold
+			m.alignment = tt.alignment
+
+			var rendered string
+			if tt.lineNumbers {
+				rendered = m.numberText(lyrics, tt.highlight)
+			} else {
+				rendered = m.centerLyrics(lyrics, tt.highlight)
+			}
+
+			lines := strings.Split(rendered, "\n")
+			for i, line := range lines {
+				if strings.TrimSpace(strings.Split(lyrics, "\n")[i]) == "" && line != "" {
+					t.Errorf("blank line %d rendered as %q, want it empty", i, line)
+				}
+				if text := ansiPattern.ReplaceAllString(line, ""); text != strings.TrimRight(text, " \t") {
+					t.Errorf("line %d %q has trailing whitespace", i, text)
+				}
+			}
+		})
+	}
+}
+
+func TestStateMessagesHaveNoTrailingWhitespace(t *testing.T) {
+	withColorProfile(t)
+	m := newTestModel(40, 10)
+	m.boldLyrics = true
+	m.stateStyle.messages[stateError] = "Something went wrong\n\n{error}"
+
+	for i, line := range strings.Split(m.stateContent(stateError, "details"), "\n") {
+		if text := ansiPattern.ReplaceAllString(line, ""); text != strings.TrimRight(text, " ") {
+			t.Errorf("line %d %q has trailing whitespace", i, text)
+		}
+	}
+}
+
+// readCmusOutput returns saved cmus-remote -Q output from testdata/cmus
+func readCmusOutput(t *testing.T, name string) string {
+	t.Helper()
+	output, err := os.ReadFile(filepath.Join("testdata", "cmus", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(output)
+}
+
+func TestParseCmusOutput(t *testing.T) {
+	tests := []struct {
+		file                 string
+		artist, album, title string
+		position, duration   int
+		playSource           string
+	}{
+		{"playing.txt", "Radiohead", "OK Computer", "Paranoid Android", 37, 215, "library"},
+		{"stream.txt", "Daft Punk", "", "One More Time", 1284, 0, "playlist"},
+		{"stopped.txt", "", "", "", 0, 0, "library"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			output := readCmusOutput(t, tt.file)
+
+			artist, album, title := parseCmusOutput(output)
+			if artist != tt.artist || album != tt.album || title != tt.title {
+				t.Errorf("parseCmusOutput = %q, %q, %q, want %q, %q, %q", artist, album, title, tt.artist, tt.album, tt.title)
+			}
+			position, duration := parseCmusProgress(output)
+			if position != tt.position || duration != tt.duration {
+				t.Errorf("parseCmusProgress = %d, %d, want %d, %d", position, duration, tt.position, tt.duration)
+			}
+			if got := cmusPlaySource(output); got != tt.playSource {
+				t.Errorf("cmusPlaySource = %q, want %q", got, tt.playSource)
+			}
+		})
+	}
+}
+
+func TestCmusTag(t *testing.T) {
+	output := readCmusOutput(t, "playing.txt")
+	tests := map[string]string{
+		"albumartist": "Radiohead",
+		"date":        "1997",
+		"composer":    "",
+	}
+	for tag, want := range tests {
+		if got := cmusTag(output, tag); got != want {
+			t.Errorf("cmusTag(%q) = %q, want %q", tag, got, want)
+		}
+	}
+}
+
+func TestParseFFprobeTags(t *testing.T) {
+	tests := []struct {
+		name                 string
+		output               string
+		artist, album, title string
+	}{
+		{"lowercase tags", "TAG:title=Creep\nTAG:artist=Radiohead\nTAG:album=Pablo Honey\n", "Radiohead", "Pablo Honey", "Creep"},
+		{"vorbis comments", "TAG:ARTIST=Radiohead\nTAG:TITLE=Creep\n", "Radiohead", "", "Creep"},
+		{"value with an equals sign", "TAG:artist=Artist\nTAG:title=1 + 1 = 2\n", "Artist", "", "1 + 1 = 2"},
+		{"no tags", "", "", "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			artist, album, title := parseFFprobeTags(tt.output)
+			if artist != tt.artist || album != tt.album || title != tt.title {
+				t.Errorf("parseFFprobeTags = %q, %q, %q, want %q, %q, %q", artist, album, title, tt.artist, tt.album, tt.title)
+			}
+		})
+	}
+}
+
+func TestParseTrackFilename(t *testing.T) {
+	tests := []struct {
+		path                 string
+		artist, album, title string
+	}{
+		{"/music/Pablo Honey/Radiohead - Creep.mp3", "Radiohead", "Pablo Honey", "Creep"},
+		{"/music/Radiohead/Creep.flac", "Radiohead", "", "Creep"},
+	}
+
+	for _, tt := range tests {
+		artist, album, title := parseTrackFilename(tt.path)
+		if artist != tt.artist || album != tt.album || title != tt.title {
+			t.Errorf("parseTrackFilename(%q) = %q, %q, %q, want %q, %q, %q", tt.path, artist, album, title, tt.artist, tt.album, tt.title)
+		}
+	}
+}
+
+func TestSelectedTrackIsNotPolled(t *testing.T) {
+	m := withSong(newTestModel(80, 24), "Artist", "Album", "Title")
+	m.followSelected = true
+	m.pollInterval = time.Hour
+
+	updated, cmd := m.Update(checkCmusTick{id: m.checkID})
+	m = updated.(model)
+	for _, msg := range runCmd(cmd) {
+		if _, ok := msg.(songInfoMsg); ok {
+			t.Error("polling read the selected track")
+		}
+	}
+	if m.checkID == 0 {
+		t.Error("no further check scheduled, want polling to resume when toggled back")
+	}
+}
+
+func TestAnnotationsFetchCancelledOnSongChange(t *testing.T) {
+	// Genius doesn't answer until the request is cancelled
+	client := newTestGeniusClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+
+	m := withSong(newTestModel(80, 24), "Artist", "Album", "First")
+	m.geniusAPIClient = client
+	fetch := m.fetchAnnotations()
+
+	m = withSong(m, "Artist", "Album", "Second")
+	m.fetchLyrics(false)
+
+	msgs := make(chan tea.Msg, 1)
+	go func() { msgs <- fetch() }()
+	var msg tea.Msg
+	select {
+	case msg = <-msgs:
+	case <-time.After(time.Second):
+		t.Fatal("annotation fetch not cancelled by the song change")
+	}
+
+	m = update(t, m, msg)
+	if m.footerMessage != "" {
+		t.Errorf("footer = %q for the previous song's annotations", m.footerMessage)
+	}
+	if m.annotationsSongID != "" {
+		t.Errorf("annotations stored for %q", m.annotationsSongID)
+	}
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"math"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Defaults for connecting to MPD, which can be overridden with the MPD_HOST
+// and MPD_PORT environment variables like the mpc client
+const (
+	defaultMPDHost = "localhost"
+	defaultMPDPort = "6600"
+)
+
+// MPDPlayer reads the current song from an MPD server over its text protocol
+type MPDPlayer struct {
+	network  string
+	address  string
+	password string
+}
+
+// NewMPDPlayer creates an MPD player from the MPD_HOST and MPD_PORT
+// environment variables. MPD_HOST may be prefixed with "password@", and may be
+// the path to a Unix socket.
+func NewMPDPlayer() *MPDPlayer {
+	host := os.Getenv("MPD_HOST")
+	port := os.Getenv("MPD_PORT")
+	if port == "" {
+		port = defaultMPDPort
+	}
+
+	p := &MPDPlayer{
+		network: "tcp",
+	}
+	if i := strings.LastIndex(host, "@"); i > 0 {
+		p.password = host[:i]
+		host = host[i+1:]
+	}
+	if host == "" {
+		host = defaultMPDHost
+	}
+
+	if strings.HasPrefix(host, "/") {
+		p.network = "unix"
+		p.address = host
+	} else {
+		p.address = net.JoinHostPort(host, port)
+	}
+	return p
+}
+
+// connect opens a connection to MPD, authenticating if a password is set.
+// The connection must be closed by the caller.
+func (p *MPDPlayer) connect(ctx context.Context) (net.Conn, *bufio.Reader, error) {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, p.network, p.address)
+	if err != nil {
+		return nil, nil, errors.New("mpd not running or not available")
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	r := bufio.NewReader(conn)
+	greeting, err := r.ReadString('\n')
+	if err != nil {
+		conn.Close()
+		return nil, nil, errors.Wrap(err, "read mpd greeting")
+	}
+	if !strings.HasPrefix(greeting, "OK MPD ") {
+		conn.Close()
+		return nil, nil, errors.Errorf("unexpected mpd greeting: %q", strings.TrimSpace(greeting))
+	}
+
+	if p.password != "" {
+		if _, err := mpdCommand(conn, r, "password "+mpdQuote(p.password)); err != nil {
+			conn.Close()
+			return nil, nil, err
+		}
+	}
+	return conn, r, nil
+}
+
+func (p *MPDPlayer) CurrentSong(ctx context.Context) (SongInfo, error) {
+	conn, r, err := p.connect(ctx)
+	if err != nil {
+		return SongInfo{}, err
+	}
+	defer conn.Close()
+
+	status, err := mpdCommand(conn, r, "status")
+	if err != nil {
+		return SongInfo{}, err
+	}
+	if status["state"] != "play" && status["state"] != "pause" {
+		return SongInfo{}, errNoSong
+	}
+
+	song, err := mpdCommand(conn, r, "currentsong")
+	if err != nil {
+		return SongInfo{}, err
+	}
+	// MPD always reports the file, so any other key is a tag
+	if len(song) <= 1 {
+		return SongInfo{}, errNoMetadata
+	}
+	if song["Artist"] == "" || song["Title"] == "" {
+		return SongInfo{}, errMissingTags
+	}
+
+	info := SongInfo{
+		Artist:      song["Artist"],
+		Album:       song["Album"],
+		Title:       song["Title"],
+		AlbumArtist: song["AlbumArtist"],
+		Composer:    song["Composer"],
+		Status:      statusPlaying,
+	}
+	info.Position, info.Duration = parseMPDProgress(status)
+	if status["state"] == "pause" {
+		info.Status = statusPaused
+	}
+	return info, nil
+}
+
+// parseMPDProgress returns the playback position and duration of the song in
+// seconds from the status response. Older versions of MPD only report them
+// together as "time: elapsed:duration", in whole seconds.
+func parseMPDProgress(status map[string]string) (position, duration int) {
+	elapsed, hasElapsed := status["elapsed"]
+	total, hasDuration := status["duration"]
+	if oldPosition, oldDuration, ok := strings.Cut(status["time"], ":"); ok {
+		if !hasElapsed {
+			elapsed = oldPosition
+		}
+		if !hasDuration {
+			total = oldDuration
+		}
+	}
+
+	// Streams have no duration, which is left at zero
+	position = int(parseMPDSeconds(elapsed))
+	duration = int(math.Round(parseMPDSeconds(total)))
+	return position, duration
+}
+
+// parseMPDSeconds parses a number of seconds, which may have a fraction, or
+// returns zero if it isn't one
+func parseMPDSeconds(s string) float64 {
+	seconds, err := strconv.ParseFloat(s, 64)
+	if err != nil || seconds < 0 {
+		return 0
+	}
+	return seconds
+}
+
+// mpdCommand sends a command and reads its response up to the terminating
+// "OK". For keys that are repeated, such as multiple artists, the first value
+// is kept.
+func mpdCommand(conn net.Conn, r *bufio.Reader, command string) (map[string]string, error) {
+	if _, err := fmt.Fprintf(conn, "%s\n", command); err != nil {
+		return nil, errors.Wrap(err, "send mpd command")
+	}
+
+	values := make(map[string]string)
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return nil, errors.Wrap(err, "read mpd response")
+		}
+		line = strings.TrimSuffix(line, "\n")
+
+		if line == "OK" {
+			return values, nil
+		}
+		if strings.HasPrefix(line, "ACK ") {
+			return nil, errors.Errorf("mpd error: %s", strings.TrimPrefix(line, "ACK "))
+		}
+
+		key, value, ok := strings.Cut(line, ": ")
+		if !ok {
+			continue
+		}
+		if _, seen := values[key]; !seen {
+			values[key] = value
+		}
+	}
+}
+
+// mpdQuote quotes an argument to an MPD command
+func mpdQuote(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `"`, `\"`)
+	return `"` + s + `"`
+}
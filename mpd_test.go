@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bufio"
+	"net"
+	"strings"
+	"testing"
+)
+
+// fakeMPDServer serves the response for each command after greeting, like
+// MPD does, and returns its host and port
+func fakeMPDServer(t *testing.T, responses map[string]string) (host, port string) {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				conn.Write([]byte("OK MPD 0.23.5\n"))
+				r := bufio.NewReader(conn)
+				for {
+					command, err := r.ReadString('\n')
+					if err != nil {
+						return
+					}
+					conn.Write([]byte(responses[strings.TrimSpace(command)] + "OK\n"))
+				}
+			}()
+		}
+	}()
+
+	host, port, _ = net.SplitHostPort(l.Addr().String())
+	return host, port
+}
+
+func TestMPDCurrentSong(t *testing.T) {
+	const song = "file: Radiohead/OK Computer/02 Paranoid Android.flac\n" +
+		"Artist: Radiohead\nAlbum: OK Computer\nTitle: Paranoid Android\n"
+
+	tests := []struct {
+		name         string
+		status       string
+		wantStatus   string
+		wantPosition int
+		wantDuration int
+	}{
+		{"elapsed and duration", "state: play\nelapsed: 37.482\nduration: 383.253\ntime: 37:383\n", statusPlaying, 37, 383},
+		{"time only on older versions", "state: pause\ntime: 120:383\n", statusPaused, 120, 383},
+		{"stream without a duration", "state: play\nelapsed: 12.000\n", statusPlaying, 12, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			host, port := fakeMPDServer(t, map[string]string{"status": tt.status, "currentsong": song})
+			t.Setenv("MPD_HOST", host)
+			t.Setenv("MPD_PORT", port)
+
+			info, err := NewMPDPlayer().CurrentSong(t.Context())
+			if err != nil {
+				t.Fatalf("CurrentSong: %v", err)
+			}
+			if info.Artist != "Radiohead" || info.Album != "OK Computer" || info.Title != "Paranoid Android" {
+				t.Errorf("song = %+v, want Radiohead - OK Computer - Paranoid Android", info)
+			}
+			if info.Status != tt.wantStatus {
+				t.Errorf("status = %q, want %q", info.Status, tt.wantStatus)
+			}
+			if info.Position != tt.wantPosition || info.Duration != tt.wantDuration {
+				t.Errorf("position, duration = %d, %d, want %d, %d", info.Position, info.Duration, tt.wantPosition, tt.wantDuration)
+			}
+		})
+	}
+}
+
+func TestMPDCurrentSongStopped(t *testing.T) {
+	host, port := fakeMPDServer(t, map[string]string{"status": "state: stop\n"})
+	t.Setenv("MPD_HOST", host)
+	t.Setenv("MPD_PORT", port)
+
+	if _, err := NewMPDPlayer().CurrentSong(t.Context()); err != errNoSong {
+		t.Errorf("CurrentSong error = %v, want errNoSong", err)
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/pkg/errors"
+)
+
+// getNotesPath returns the path to the file of per-song notes
+func getNotesPath() (string, error) {
+	configDir, err := getConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(configDir, "notes.json"), nil
+}
+
+// loadNotes reads the notes at path, keyed by song ID. A missing file means
+// there are no notes yet.
+func loadNotes(path string) (map[string]string, error) {
+	notes := make(map[string]string)
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return notes, nil
+		}
+		return notes, errors.Wrap(err, "read notes file")
+	}
+
+	if err := json.Unmarshal(data, &notes); err != nil {
+		return notes, errors.Wrap(err, "parse notes file")
+	}
+	return notes, nil
+}
+
+// saveNotes writes the notes to path, replacing the file atomically so that
+// an interrupted write doesn't lose existing notes
+func saveNotes(path string, notes map[string]string) error {
+	data, err := json.MarshalIndent(notes, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "encode notes")
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return errors.Wrap(err, "create notes directory")
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return errors.Wrap(err, "write notes file")
+	}
+	return errors.Wrap(os.Rename(tmp, path), "write notes file")
+}
+
+// notesSavedMsg reports the result of saving the notes
+type notesSavedMsg struct {
+	err error
+}
+
+// saveNotesCmd is a command to save the notes. The map is copied, since the
+// model may change it before the command runs.
+func saveNotesCmd(path string, notes map[string]string) tea.Cmd {
+	snapshot := make(map[string]string, len(notes))
+	for songID, note := range notes {
+		snapshot[songID] = note
+	}
+
+	return func() tea.Msg {
+		return notesSavedMsg{err: saveNotes(path, snapshot)}
+	}
+}
+
+// toggleNote shows the note for the current song in place of the lyrics, or
+// shows the lyrics again if a note or annotation is already shown
+func (m *model) toggleNote() tea.Cmd {
+	if m.showingAnnotation {
+		m.hideAnnotation()
+		return nil
+	}
+
+	note, ok := m.notes[m.currentSongID]
+	if !ok {
+		return m.flashFooterMessage("No note for this song. Press O to add one.")
+	}
+
+	m.annotationYOffset = m.viewport.YOffset
+	m.showingAnnotation = true
+	m.viewport.SetContent(m.centerText("Note\n\n" + note))
+	m.viewport.GotoTop()
+	return nil
+}
+
+// applyNote stores the edited note for the current song, or removes it if
+// the note was cleared
+func (m *model) applyNote(note string) tea.Cmd {
+	if note == "" {
+		delete(m.notes, m.currentSongID)
+	} else {
+		m.notes[m.currentSongID] = note
+	}
+	m.updateStatusBar()
+
+	if m.notesFile == "" {
+		return nil
+	}
+	return saveNotesCmd(m.notesFile, m.notes)
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// notifyCooldown is the minimum time between desktop notifications, so that
+// skipping quickly through songs doesn't spam them
+const notifyCooldown = 10 * time.Second
+
+// notifyLyricsWait is how long a song's notification waits for its lyrics
+const notifyLyricsWait = 2 * time.Second
+
+// findNotifier returns the desktop notification tool available on this
+// system, or an empty string if there is none
+func findNotifier() string {
+	for _, name := range []string{"notify-send", "osascript"} {
+		if _, err := exec.LookPath(name); err == nil {
+			return name
+		}
+	}
+	return ""
+}
+
+// lyricsSnippet returns the first lines of the lyrics for a notification,
+// skipping blank lines and section headers such as "[Chorus]"
+func lyricsSnippet(lyrics string, maxLines int) string {
+	var lines []string
+	for _, line := range strings.Split(lyrics, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "[") {
+			continue
+		}
+		lines = append(lines, line)
+		if len(lines) == maxLines {
+			break
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// sendNotificationCmd shows a desktop notification using the given notifier.
+// Failures are ignored since notifications are best effort.
+func sendNotificationCmd(notifier, summary, body string) tea.Cmd {
+	return func() tea.Msg {
+		var cmd *exec.Cmd
+		switch notifier {
+		case "notify-send":
+			cmd = exec.Command("notify-send", summary, body)
+		case "osascript":
+			// Pass the text as arguments to avoid AppleScript escaping
+			cmd = exec.Command("osascript",
+				"-e", "on run argv",
+				"-e", "display notification (item 2 of argv) with title (item 1 of argv)",
+				"-e", "end run",
+				summary, body)
+		default:
+			return nil
+		}
+		_ = cmd.Run()
+		return nil
+	}
+}
+
+// notifyTick is sent once a song's notification is due
+type notifyTick struct {
+	songID string
+}
+
+// notifySongCmd schedules the notification for a song that has started
+// playing. It waits a moment for the lyrics so that they can be included,
+// and until the cooldown is over, but is sent whether or not they're found.
+func (m *model) notifySongCmd(songID string) tea.Cmd {
+	if m.notifier == "" {
+		return nil
+	}
+	wait := max(notifyLyricsWait, notifyCooldown-time.Since(m.lastNotified))
+	return tea.Tick(wait, func(t time.Time) tea.Msg {
+		return notifyTick{songID: songID}
+	})
+}
+
+// notifySong notifies about the song if it's still playing and hasn't been
+// notified about yet. Within the cooldown the notification is delayed until
+// it's over rather than dropped, so that the song settled on after skipping
+// is still notified about.
+func (m *model) notifySong(songID string) tea.Cmd {
+	if m.notifier == "" || songID == m.notifiedSongID || songID != generateSongID(m.artist, m.album, m.title) {
+		return nil
+	}
+	if wait := notifyCooldown - time.Since(m.lastNotified); wait > 0 {
+		return tea.Tick(wait, func(t time.Time) tea.Msg {
+			return notifyTick{songID: songID}
+		})
+	}
+
+	m.notifiedSongID = songID
+	m.lastNotified = time.Now()
+	summary := fmt.Sprintf("%s - %s", m.artist, m.title)
+	return sendNotificationCmd(m.notifier, summary, m.notificationBody(songID))
+}
+
+// notificationBody returns the first lines of the song's lyrics if they're
+// shown, or nothing if they're still loading or weren't found
+func (m *model) notificationBody(songID string) string {
+	if songID != m.lyricsSongID || m.state != "" {
+		return ""
+	}
+	return lyricsSnippet(m.lyrics, 2)
+}
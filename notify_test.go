@@ -0,0 +1,103 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// playSong returns the model with the song playing, as reported by the
+// player
+func playSong(t *testing.T, m model, artist, title string) model {
+	t.Helper()
+	return update(t, m, songInfoMsg{artist: artist, title: title, status: statusPlaying})
+}
+
+func TestNotificationDelayedByCooldown(t *testing.T) {
+	m := newTestModel(80, 24)
+	m.notifier = "notify-send"
+
+	first := generateSongID("Artist", "", "First")
+	m = playSong(t, m, "Artist", "First")
+	m = update(t, m, notifyTick{songID: first})
+	if m.notifiedSongID != first {
+		t.Fatalf("notified about %q, want the first song", m.notifiedSongID)
+	}
+
+	// Skipping through songs within the cooldown delays their notifications
+	m = playSong(t, m, "Artist", "Second")
+	m = update(t, m, notifyTick{songID: generateSongID("Artist", "", "Second")})
+	m = playSong(t, m, "Artist", "Third")
+	if m.notifiedSongID != first {
+		t.Fatalf("notified about %q within the cooldown", m.notifiedSongID)
+	}
+
+	// Once the cooldown is over, only the song still playing is notified
+	// about
+	m.lastNotified = time.Now().Add(-notifyCooldown)
+	m = update(t, m, notifyTick{songID: generateSongID("Artist", "", "Second")})
+	if m.notifiedSongID != first {
+		t.Errorf("notified about %q, a song no longer playing", m.notifiedSongID)
+	}
+	m = update(t, m, notifyTick{songID: generateSongID("Artist", "", "Third")})
+	if want := generateSongID("Artist", "", "Third"); m.notifiedSongID != want {
+		t.Errorf("notified about %q, want the delayed notification for the song playing", m.notifiedSongID)
+	}
+}
+
+func TestNotificationIndependentOfFetch(t *testing.T) {
+	songID := generateSongID("Artist", "", "Title")
+
+	tests := []struct {
+		name     string
+		fetched  func(m model) model
+		wantBody string
+	}{
+		{"lyrics shown", func(m model) model {
+			return update(t, m, songLyricsMsg{artist: "Artist", title: "Title", lyrics: "[Verse 1]\nFirst line\nSecond line\nThird line"})
+		}, "First line\nSecond line"},
+		{"lyrics not found", func(m model) model {
+			return update(t, m, songLyricsMsg{artist: "Artist", title: "Title", err: &NotFoundError{}})
+		}, ""},
+		{"lyrics still loading", func(m model) model { return m }, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newTestModel(80, 24)
+			m.notifier = "notify-send"
+			m = tt.fetched(playSong(t, m, "Artist", "Title"))
+
+			if body := m.notificationBody(songID); body != tt.wantBody {
+				t.Errorf("body = %q, want %q", body, tt.wantBody)
+			}
+			m = update(t, m, notifyTick{songID: songID})
+			if m.notifiedSongID != songID {
+				t.Errorf("notified about %q, want the song playing", m.notifiedSongID)
+			}
+		})
+	}
+}
+
+func TestNotifySongCmd(t *testing.T) {
+	tests := []struct {
+		name     string
+		notifier string
+		wantCmd  bool
+	}{
+		{"notifications disabled", "", false},
+		{"notifications enabled", "notify-send", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newTestModel(80, 24)
+			m.notifier = tt.notifier
+			if cmd := m.notifySongCmd("song"); (cmd != nil) != tt.wantCmd {
+				t.Errorf("got command %v, want a command %v", cmd != nil, tt.wantCmd)
+			}
+			if m.notifiedSongID != "" {
+				t.Errorf("notified about %q before the notification was due", m.notifiedSongID)
+			}
+		})
+	}
+}
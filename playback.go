@@ -0,0 +1,216 @@
+package main
+
+import (
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/progress"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// playbackTickInterval is how often the lyrics are updated to follow
+// playback, by auto-scrolling or highlighting the line being sung
+const playbackTickInterval = time.Second
+
+// autoScrollIdle is how long auto-scrolling holds off after the user scrolls
+// by hand, so that it doesn't fight them
+const autoScrollIdle = 10 * time.Second
+
+// Bounds on the width of the progress bar in the footer. It takes up to a
+// quarter of the terminal, and is hidden if that's narrower than the minimum.
+const (
+	progressBarMinWidth = 8
+	progressBarMaxWidth = 30
+)
+
+// playbackTick is sent every playbackTickInterval while auto-scrolling or
+// showing synced lyrics
+type playbackTick struct {
+	id int
+}
+
+// schedulePlaybackTick schedules the next playback tick, superseding any
+// scheduled earlier
+func (m *model) schedulePlaybackTick() tea.Cmd {
+	m.playbackTickID++
+	id := m.playbackTickID
+	return tea.Tick(playbackTickInterval, func(t time.Time) tea.Msg {
+		return playbackTick{id: id}
+	})
+}
+
+// followPlayback updates the lyrics for the playback position, and returns
+// the next tick if they still need following
+func (m *model) followPlayback() tea.Cmd {
+	if len(m.syncedLyrics) > 0 && m.state == "" && !m.showingAnnotation {
+		m.updateLyrics(m.lyrics)
+	}
+	if m.autoScroll && time.Since(m.lastManualScroll) >= autoScrollIdle {
+		m.scrollToPlayback()
+	}
+
+	if !m.autoScroll && len(m.syncedLyrics) == 0 {
+		return nil
+	}
+	return m.schedulePlaybackTick()
+}
+
+// toggleAutoScroll turns scrolling along with playback on or off
+func (m *model) toggleAutoScroll() tea.Cmd {
+	m.autoScroll = !m.autoScroll
+	if !m.autoScroll {
+		return m.flashFooterMessage("Auto-scroll off")
+	}
+
+	m.lastManualScroll = time.Time{}
+	m.scrollToPlayback()
+	return tea.Batch(m.flashFooterMessage("Auto-scroll on"), m.schedulePlaybackTick())
+}
+
+// playbackPosition estimates the playback position, counting the time
+// since the player was last checked unless it's paused
+func (m *model) playbackPosition() time.Duration {
+	position := time.Duration(m.position) * time.Second
+	if m.playbackStatus != statusPaused && !m.positionAt.IsZero() {
+		position += time.Since(m.positionAt)
+	}
+	return position
+}
+
+// currentLyricLine returns the index of the lyric line being sung, or -1 if
+// it can't be told. Synced lyrics give the exact line; otherwise it's
+// estimated from how far through the song playback is.
+func (m *model) currentLyricLine() int {
+	lines := strings.Split(m.lyrics, "\n")
+	position := m.playbackPosition()
+
+	if len(m.syncedLyrics) > 0 {
+		return syncedLyricLine(lines, m.syncedLyrics, position)
+	}
+
+	if m.duration <= 0 {
+		return -1
+	}
+	fraction := position.Seconds() / float64(m.duration)
+	if fraction > 1 {
+		fraction = 1
+	}
+	return int(fraction * float64(len(lines)-1))
+}
+
+// highlightedLine returns the index of the lyric line to highlight as being
+// sung, or -1 if the lyrics aren't synced
+func (m *model) highlightedLine() int {
+	if len(m.syncedLyrics) == 0 {
+		return -1
+	}
+	return m.currentLyricLine()
+}
+
+// lyricStyle styles a lyric line karaoke-style: the highlighted line stands
+// out and the lines before it are dimmed. Lines are left alone when nothing
+// is highlighted.
+func (m *model) lyricStyle(style lipgloss.Style, line, highlight int) lipgloss.Style {
+	switch {
+	case highlight < 0:
+		return style
+	case line == highlight:
+		return style.Bold(true).Foreground(m.palette.highlightFg)
+	case line < highlight:
+		return style.Foreground(m.palette.footer)
+	}
+	return style
+}
+
+// syncedLyricLine returns the index of the lyric line being sung at the
+// position, or -1 before the first synced line. Synced lines are matched to
+// the lyrics in order, so that repeated lines such as choruses resolve to
+// the right occurrence.
+func syncedLyricLine(lines []string, synced []LyricLine, position time.Duration) int {
+	current := -1
+	next := 0
+	for _, s := range synced {
+		if s.Time > position {
+			break
+		}
+
+		text := normalizeSongIDField(s.Text)
+		if text == "" {
+			continue
+		}
+		for i := next; i < len(lines); i++ {
+			if normalizeSongIDField(lines[i]) == text {
+				current = i
+				next = i + 1
+				break
+			}
+		}
+	}
+	return current
+}
+
+// scrollToPlayback scrolls the lyrics so that the line being sung is in the
+// middle of the viewport
+func (m *model) scrollToPlayback() {
+	if m.state != "" || m.showingAnnotation || m.lyricsSongID != generateSongID(m.artist, m.album, m.title) {
+		return
+	}
+
+	line := m.currentLyricLine()
+	if line < 0 {
+		return
+	}
+	m.viewport.SetYOffset(m.lyricRow(line) - m.viewport.Height/2)
+}
+
+// lyricRow returns the row of the viewport content that the lyric line
+// starts on, since long lines wrap onto several rows and short lyrics may be
+// padded to the middle of the viewport
+func (m *model) lyricRow(line int) int {
+	render := m.centerText
+	if m.showLineNumbers {
+		render = func(text string) string { return m.numberText(text, -1) }
+	}
+
+	row := m.topPadding(lipgloss.Height(render(m.lyrics)))
+	if line > 0 {
+		before := strings.Join(strings.Split(m.lyrics, "\n")[:line], "\n")
+		row += lipgloss.Height(render(before))
+	}
+	return row
+}
+
+// newProgressBar returns the bar showing how far through the song playback
+// is, drawn as a thin line in the palette's colors
+func newProgressBar(p palette) progress.Model {
+	bar := progress.New(progress.WithoutPercentage(), progress.WithSolidFill(string(p.highlightFg)))
+	bar.Full = '━'
+	bar.Empty = '─'
+	bar.EmptyColor = string(p.footer)
+	return bar
+}
+
+// progressBarView renders the progress bar as of the last player check, or
+// returns an empty string if the player doesn't report the position or the
+// terminal is too narrow
+func (m *model) progressBarView() string {
+	if m.duration <= 0 {
+		return ""
+	}
+
+	width := m.viewport.Width / 4
+	if width > progressBarMaxWidth {
+		width = progressBarMaxWidth
+	}
+	if width < progressBarMinWidth {
+		return ""
+	}
+
+	percent := float64(m.position) / float64(m.duration)
+	if percent > 1 {
+		percent = 1
+	}
+	m.progress.Width = width
+	return m.progress.ViewAs(percent)
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"os/exec"
+	"regexp"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/pkg/errors"
+)
+
+// Names of the supported players, see Config.Player
+const (
+	playerCmus      = "cmus"
+	playerMPD       = "mpd"
+	playerPlayerctl = "playerctl"
+)
+
+// Playback states of a player. An empty status means it's unknown, such as
+// when the player can't be reached.
+const (
+	statusPlaying = "playing"
+	statusPaused  = "paused"
+	statusStopped = "stopped"
+)
+
+// cmusStatusPattern matches the playback status reported by cmus-remote -Q
+var cmusStatusPattern = regexp.MustCompile(`(?m)^status (playing|paused)$`)
+
+// playerTimeout bounds how long a player is queried for the current song, so
+// that a hung player doesn't stall polling
+const playerTimeout = 2 * time.Second
+
+var (
+	// errNoSong is returned by a player that's stopped
+	errNoSong = errors.New("no song playing")
+
+	// errNoMetadata is returned when the current song has no tags at all,
+	// in which case there's nothing to look up
+	errNoMetadata = errors.New("no track metadata")
+
+	// errMissingTags is returned when the current song is missing its
+	// artist or title
+	errMissingTags = errors.New("missing artist or title information")
+)
+
+// SongInfo is the song a player is currently playing
+type SongInfo struct {
+	Artist      string
+	Album       string
+	Title       string
+	AlbumArtist string
+	Composer    string
+
+	// Whether the song is played from the "library" or a "playlist", if the
+	// player distinguishes them
+	PlaySource string
+
+	// Status is statusPlaying or statusPaused
+	Status string
+
+	// Playback position and length of the song in seconds, or zero if
+	// unknown
+	Position int
+	Duration int
+}
+
+// Player reports the song a music player is currently playing. It returns
+// errNoSong if nothing is playing.
+type Player interface {
+	CurrentSong(ctx context.Context) (SongInfo, error)
+}
+
+// newPlayer returns the player with the given name
+func newPlayer(name string) (Player, error) {
+	switch name {
+	case playerCmus:
+		return &CmusPlayer{}, nil
+	case playerMPD:
+		return NewMPDPlayer(), nil
+	case playerPlayerctl:
+		return &PlayerctlPlayer{}, nil
+	}
+	return nil, errors.Errorf("unknown player %q", name)
+}
+
+// CmusPlayer reads the current song from cmus-remote
+type CmusPlayer struct{}
+
+func (p *CmusPlayer) CurrentSong(ctx context.Context) (SongInfo, error) {
+	// Run cmus-remote -Q to get current song information
+	cmd := exec.CommandContext(ctx, "cmus-remote", "-Q")
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return SongInfo{}, errors.New("cmus not running or not available")
+	}
+
+	// Check if cmus is playing something
+	outputStr := string(output)
+	status := cmusStatusPattern.FindStringSubmatch(outputStr)
+	if status == nil {
+		return SongInfo{}, errNoSong
+	}
+
+	// cmus may only print its settings without any tags
+	if !regexp.MustCompile(`(?m)^tag `).MatchString(outputStr) {
+		return SongInfo{}, errNoMetadata
+	}
+
+	artist, album, title := parseCmusOutput(outputStr)
+	if artist == "" || title == "" {
+		return SongInfo{}, errMissingTags
+	}
+
+	position, duration := parseCmusProgress(outputStr)
+	song := SongInfo{
+		Artist:      artist,
+		Album:       album,
+		Title:       title,
+		AlbumArtist: cmusTag(outputStr, "albumartist"),
+		Composer:    cmusTag(outputStr, "composer"),
+		PlaySource:  cmusPlaySource(outputStr),
+		Status:      status[1],
+		Position:    position,
+		Duration:    duration,
+	}
+	return song, nil
+}
+
+// checkPlayerCmd checks the player for the current song. Errors are reported
+// through the song info's title, which is shown in place of the song.
+func checkPlayerCmd(player Player) tea.Cmd {
+	return func() tea.Msg {
+		ctx, cancel := context.WithTimeout(context.Background(), playerTimeout)
+		defer cancel()
+
+		song, err := player.CurrentSong(ctx)
+		switch {
+		case errors.Is(err, errNoSong):
+			return songInfoMsg{title: "No song playing", noSong: true, status: statusStopped}
+		case errors.Is(err, errNoMetadata):
+			return songInfoMsg{title: "No track metadata"}
+		case errors.Is(err, errMissingTags):
+			return songInfoMsg{title: "Unknown song", err: err}
+		case err != nil:
+			return songInfoMsg{title: "Error: " + err.Error(), err: err}
+		}
+
+		// Return the song info without fetching lyrics yet
+		return songInfoMsg{
+			artist:      song.Artist,
+			album:       song.Album,
+			title:       song.Title,
+			albumArtist: song.AlbumArtist,
+			composer:    song.Composer,
+			playSource:  song.PlaySource,
+			status:      song.Status,
+			position:    song.Position,
+			duration:    song.Duration,
+		}
+	}
+}
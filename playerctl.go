@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"os/exec"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// playerctlFormat is the playerctl metadata template, with fields separated
+// by tabs since they won't appear in tags
+const playerctlFormat = "{{status}}\t{{artist}}\t{{album}}\t{{title}}"
+
+// PlayerctlPlayer reads the current song with playerctl, which supports any
+// player that implements MPRIS, such as Spotify, VLC, mpv and browsers
+type PlayerctlPlayer struct{}
+
+func (p *PlayerctlPlayer) CurrentSong(ctx context.Context) (SongInfo, error) {
+	cmd := exec.CommandContext(ctx, "playerctl", "metadata", "--format", playerctlFormat)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			// playerctl exits with an error when no player is running
+			return SongInfo{}, errNoSong
+		}
+		return SongInfo{}, errors.New("playerctl not available")
+	}
+
+	return parsePlayerctlOutput(string(output))
+}
+
+// parsePlayerctlOutput parses the output of playerctl metadata with
+// playerctlFormat
+func parsePlayerctlOutput(output string) (SongInfo, error) {
+	fields := strings.Split(strings.TrimRight(output, "\n"), "\t")
+	if len(fields) != 4 {
+		return SongInfo{}, errors.Errorf("unexpected playerctl output: %q", output)
+	}
+
+	status, artist, album, title := fields[0], fields[1], fields[2], fields[3]
+	if status != "Playing" && status != "Paused" {
+		return SongInfo{}, errNoSong
+	}
+	if artist == "" && album == "" && title == "" {
+		return SongInfo{}, errNoMetadata
+	}
+	if artist == "" || title == "" {
+		return SongInfo{}, errMissingTags
+	}
+
+	song := SongInfo{
+		Artist: artist,
+		Album:  album,
+		Title:  title,
+		Status: statusPlaying,
+	}
+	if status == "Paused" {
+		song.Status = statusPaused
+	}
+	return song, nil
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Names of the lyrics providers, see Config.Providers
+const (
+	providerLocal  = "local"
+	providerGenius = "genius"
+	providerLRCLIB = "lrclib"
+)
+
+// NotFoundError is returned by a lyrics provider that has no lyrics for a
+// song, as opposed to failing to look them up
+type NotFoundError struct {
+	Provider string
+}
+
+func (e *NotFoundError) Error() string {
+	return "no lyrics found"
+}
+
+// LyricsProvider looks up the lyrics of a song
+type LyricsProvider interface {
+	GetLyrics(ctx context.Context, artist string, album string, title string) (string, error)
+}
+
+// SyncedLyricsProvider looks up time-synced lyrics, so that they can follow
+// playback. The duration is zero if it isn't known.
+type SyncedLyricsProvider interface {
+	GetSyncedLyrics(ctx context.Context, artist string, album string, title string, duration time.Duration) ([]LyricLine, error)
+}
+
+// chainEntry is a provider in a ChainProvider
+type chainEntry struct {
+	name     string
+	provider LyricsProvider
+
+	// Remote providers are skipped while their breaker is open, and their
+	// lyrics are cached
+	remote  bool
+	breaker *circuitBreaker
+}
+
+// ChainProvider tries each of its providers in order and returns the first
+// lyrics found. The cache is checked before the first remote provider.
+type ChainProvider struct {
+	entries []chainEntry
+	cache   *lyricsCache
+}
+
+func NewChainProvider(cache *lyricsCache) *ChainProvider {
+	c := &ChainProvider{
+		cache: cache,
+	}
+	return c
+}
+
+// AddLocal adds a provider that reads lyrics from disk, which is neither
+// cached nor skipped on failure
+func (c *ChainProvider) AddLocal(name string, provider LyricsProvider) {
+	c.entries = append(c.entries, chainEntry{name: name, provider: provider})
+}
+
+// AddRemote adds a provider that fetches lyrics over the network
+func (c *ChainProvider) AddRemote(name string, provider LyricsProvider) {
+	c.entries = append(c.entries, chainEntry{
+		name:     name,
+		provider: provider,
+		remote:   true,
+		breaker:  newCircuitBreaker(name),
+	})
+}
+
+// forRefresh returns a chain that fetches lyrics again rather than using the
+// cache, while still caching the result
+func (c *ChainProvider) forRefresh() *ChainProvider {
+	refresh := *c
+	refresh.cache = c.cache.forRefresh()
+	return &refresh
+}
+
+// isCached reports whether the song's lyrics are in the cache
+func (c *ChainProvider) isCached(artist, title string) bool {
+	_, ok := c.cache.get(artist, title)
+	return ok
+}
+
+// GetLyrics returns the lyrics from the first provider that has them. If
+// they all fail, the errors are combined; it's a NotFoundError only if no
+// provider had the song.
+func (c *ChainProvider) GetLyrics(ctx context.Context, artist string, album string, title string) (string, error) {
+	var errs chainError
+	checkedCache := false
+	for _, entry := range c.entries {
+		if entry.remote && !checkedCache {
+			checkedCache = true
+			if cached, ok := c.cache.get(artist, title); ok {
+				return cached.Lyrics, nil
+			}
+		}
+
+		if !entry.breaker.allow() {
+			errs = append(errs, entry.breaker.skippedError())
+			continue
+		}
+
+		lyrics, err := entry.provider.GetLyrics(ctx, artist, album, title)
+		if errors.Is(err, context.Canceled) {
+			entry.breaker.cancel()
+			return "", err
+		}
+
+		// A missing token isn't an outage, and tripping the breaker would
+		// hide the explanation of how to set one
+		var notFound *NotFoundError
+		entry.breaker.record(err != nil && !errors.As(err, &notFound) && !errors.Is(err, errNoAccessToken))
+		if err != nil {
+			errs = append(errs, errors.Wrap(err, entry.name))
+			continue
+		}
+
+		if entry.remote {
+			// The cache is only an optimization, so failing to write it is
+			// ignored
+			_ = c.cache.put(artist, title, lyrics)
+		}
+		return lyrics, nil
+	}
+
+	if len(errs) == 0 {
+		return "", errors.New("no lyrics providers configured")
+	}
+	return "", errs
+}
+
+// GetSyncedLyrics returns the synced lyrics from the first provider that has
+// them. Synced lyrics aren't cached.
+func (c *ChainProvider) GetSyncedLyrics(ctx context.Context, artist string, album string, title string, duration time.Duration) ([]LyricLine, error) {
+	for _, entry := range c.entries {
+		provider, ok := entry.provider.(SyncedLyricsProvider)
+		if !ok || !entry.breaker.allow() {
+			continue
+		}
+
+		lines, err := provider.GetSyncedLyrics(ctx, artist, album, title, duration)
+		if errors.Is(err, context.Canceled) {
+			entry.breaker.cancel()
+			return nil, err
+		}
+
+		var notFound *NotFoundError
+		entry.breaker.record(err != nil && !errors.As(err, &notFound))
+		if err == nil {
+			return lines, nil
+		}
+	}
+	return nil, &NotFoundError{}
+}
+
+// Status describes the health of the remote providers for the debug footer
+func (c *ChainProvider) Status() string {
+	var states []string
+	for _, entry := range c.entries {
+		if entry.breaker != nil {
+			states = append(states, entry.breaker.String())
+		}
+	}
+	return strings.Join(states, ", ")
+}
+
+// chainError combines the errors of every provider in a chain
+type chainError []error
+
+func (e chainError) Error() string {
+	if len(e) == 1 {
+		return e[0].Error()
+	}
+
+	messages := make([]string, len(e))
+	for i, err := range e {
+		messages[i] = err.Error()
+	}
+	return "all providers failed: " + strings.Join(messages, "; ")
+}
+
+// Unwrap allows errors.As to find a NotFoundError only when every provider
+// failed that way, so that real failures aren't mistaken for missing lyrics
+func (e chainError) Unwrap() error {
+	var notFound *NotFoundError
+	for _, err := range e {
+		if !errors.As(err, &notFound) {
+			return nil
+		}
+	}
+	return notFound
+}
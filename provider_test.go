@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+// stubProvider returns fixed lyrics or an error, and counts its calls
+type stubProvider struct {
+	lyrics string
+	err    error
+	calls  int
+}
+
+func (p *stubProvider) GetLyrics(ctx context.Context, artist, album, title string) (string, error) {
+	p.calls++
+	return p.lyrics, p.err
+}
+
+func TestChainProvider(t *testing.T) {
+	notFound := func() error { return &NotFoundError{} }
+	failure := errors.New("connection refused")
+
+	tests := []struct {
+		name         string
+		providers    []*stubProvider
+		wantLyrics   string
+		wantNotFound bool
+		wantErr      bool
+		wantCalls    []int
+	}{
+		{
+			name:       "first success is returned",
+			providers:  []*stubProvider{{lyrics: "first"}, {lyrics: "second"}},
+			wantLyrics: "first",
+			wantCalls:  []int{1, 0},
+		},
+		{
+			name:       "not found falls through to the next provider",
+			providers:  []*stubProvider{{err: notFound()}, {lyrics: "second"}, {lyrics: "third"}},
+			wantLyrics: "second",
+			wantCalls:  []int{1, 1, 0},
+		},
+		{
+			name:       "failure falls through to the next provider",
+			providers:  []*stubProvider{{err: failure}, {lyrics: "second"}},
+			wantLyrics: "second",
+			wantCalls:  []int{1, 1},
+		},
+		{
+			name:         "all not found",
+			providers:    []*stubProvider{{err: notFound()}, {err: notFound()}},
+			wantErr:      true,
+			wantNotFound: true,
+			wantCalls:    []int{1, 1},
+		},
+		{
+			name:      "a failure isn't reported as not found",
+			providers: []*stubProvider{{err: notFound()}, {err: failure}},
+			wantErr:   true,
+			wantCalls: []int{1, 1},
+		},
+		{
+			name:    "no providers",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chain := NewChainProvider(nil)
+			for _, p := range tt.providers {
+				chain.AddLocal("stub", p)
+			}
+
+			lyrics, err := chain.GetLyrics(context.Background(), "Artist", "Album", "Title")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("GetLyrics error = %v, want error %v", err, tt.wantErr)
+			}
+			if lyrics != tt.wantLyrics {
+				t.Errorf("lyrics = %q, want %q", lyrics, tt.wantLyrics)
+			}
+			var notFound *NotFoundError
+			if got := errors.As(err, &notFound); got != tt.wantNotFound {
+				t.Errorf("error %v is a NotFoundError: %v, want %v", err, got, tt.wantNotFound)
+			}
+			for i, p := range tt.providers {
+				if p.calls != tt.wantCalls[i] {
+					t.Errorf("provider %d called %d times, want %d", i, p.calls, tt.wantCalls[i])
+				}
+			}
+		})
+	}
+}
+
+func TestChainErrorCombinesMessages(t *testing.T) {
+	err := chainError{errors.New("genius: timeout"), errors.New("lrclib: no lyrics found")}
+	want := "all providers failed: genius: timeout; lrclib: no lyrics found"
+	if err.Error() != want {
+		t.Errorf("Error() = %q, want %q", err.Error(), want)
+	}
+}
+
+func TestChainProviderSkipsOpenBreaker(t *testing.T) {
+	remote := &stubProvider{err: errors.New("unavailable")}
+	local := &stubProvider{lyrics: "local"}
+
+	chain := NewChainProvider(nil)
+	chain.AddRemote("remote", remote)
+	chain.AddLocal("local", local)
+
+	for i := 0; i < breakerFailureThreshold+2; i++ {
+		if _, err := chain.GetLyrics(context.Background(), "Artist", "", "Title"); err != nil {
+			t.Fatalf("GetLyrics: %v", err)
+		}
+	}
+	if remote.calls != breakerFailureThreshold {
+		t.Errorf("remote provider called %d times, want %d before it's skipped", remote.calls, breakerFailureThreshold)
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+// renderCacheSize is the number of rendered layouts kept per song
+const renderCacheSize = 4
+
+// renderCacheKey identifies the layout options content was rendered with
+type renderCacheKey struct {
+	width       int
+	height      int
+	lineNumbers bool
+	bold        bool
+
+	// Index of the highlighted line, or -1 if there's none
+	highlight int
+
+	// Query whose matches are highlighted, if any
+	search string
+}
+
+// renderCache holds rendered lyrics for the most recently used layouts, so
+// that switching back to a previous terminal width doesn't re-render them
+type renderCache struct {
+	lyrics  string
+	entries map[renderCacheKey]string
+	order   []renderCacheKey
+}
+
+func newRenderCache() *renderCache {
+	c := &renderCache{
+		entries: make(map[renderCacheKey]string),
+	}
+	return c
+}
+
+// get returns the cached content for the lyrics rendered with the given key
+func (c *renderCache) get(lyrics string, key renderCacheKey) (string, bool) {
+	if lyrics != c.lyrics {
+		return "", false
+	}
+	content, ok := c.entries[key]
+	return content, ok
+}
+
+// put caches content for the lyrics, evicting the oldest layout when full.
+// Content for other lyrics is discarded.
+func (c *renderCache) put(lyrics string, key renderCacheKey, content string) {
+	if lyrics != c.lyrics {
+		c.lyrics = lyrics
+		c.entries = make(map[renderCacheKey]string)
+		c.order = nil
+	}
+
+	if _, ok := c.entries[key]; !ok {
+		if len(c.order) >= renderCacheSize {
+			delete(c.entries, c.order[0])
+			c.order = c.order[1:]
+		}
+		c.order = append(c.order, key)
+	}
+	c.entries[key] = content
+}
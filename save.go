@@ -0,0 +1,78 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/pkg/errors"
+)
+
+// defaultSavePathTemplate is where lyrics are saved unless configured
+// otherwise. Placeholders are filled in by expandSavePathTemplate.
+const defaultSavePathTemplate = "~/.local/share/lyrics/{artist} - {title}.txt"
+
+// sanitizeFilename makes a tag safe to use as a single path component, so
+// that slashes in tags don't create directories or escape the save directory
+func sanitizeFilename(s string) string {
+	s = strings.Map(func(r rune) rune {
+		switch r {
+		case '/', '\\', 0:
+			return '_'
+		}
+		return r
+	}, strings.TrimSpace(s))
+
+	if s == "" || s == "." || s == ".." {
+		return "_"
+	}
+	return s
+}
+
+// expandSavePathTemplate fills in the {artist}, {album} and {title}
+// placeholders of a save path template with sanitized tags
+func expandSavePathTemplate(template, artist, album, title string) string {
+	path := strings.NewReplacer(
+		"{artist}", sanitizeFilename(artist),
+		"{album}", sanitizeFilename(album),
+		"{title}", sanitizeFilename(title),
+	).Replace(template)
+	return expandHome(path)
+}
+
+// saveLyrics writes the lyrics to path, creating its directory if needed
+func saveLyrics(path, lyrics string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return errors.Wrap(err, "create lyrics directory")
+	}
+	return errors.Wrap(os.WriteFile(path, []byte(lyrics+"\n"), 0644), "write lyrics file")
+}
+
+// lyricsSavedMsg reports the result of saving the lyrics to a file
+type lyricsSavedMsg struct {
+	path string
+	err  error
+}
+
+// saveLyricsCmd is a command to save the lyrics to path
+func saveLyricsCmd(path, lyrics string) tea.Cmd {
+	return func() tea.Msg {
+		return lyricsSavedMsg{path: path, err: saveLyrics(path, lyrics)}
+	}
+}
+
+// saveCurrentLyrics saves the displayed lyrics to the path given by the save
+// path template
+func (m *model) saveCurrentLyrics() tea.Cmd {
+	if m.state != "" || m.lyricsSongID == "" {
+		return m.flashFooterMessage("No lyrics to save")
+	}
+
+	template := m.savePathTemplate
+	if template == "" {
+		template = defaultSavePathTemplate
+	}
+	path := expandSavePathTemplate(template, m.artist, m.album, m.title)
+	return saveLyricsCmd(path, m.lyrics)
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// searchState is a search within the displayed lyrics
+type searchState struct {
+	query string
+
+	// Case-insensitive pattern for the query
+	pattern *regexp.Regexp
+
+	// Indexes of the lyric lines containing a match, and the one jumped to
+	matches []int
+	current int
+}
+
+// active reports whether there's a search with matches to move between
+func (s searchState) active() bool {
+	return len(s.matches) > 0
+}
+
+// startSearchPrompt opens the search prompt in the footer
+func (m *model) startSearchPrompt() tea.Cmd {
+	if m.state != "" || m.showingAnnotation {
+		return nil
+	}
+
+	cmd := m.startEdit("search", "")
+	m.editInput.Prompt = "/"
+	return cmd
+}
+
+// searchLyrics finds the lines of the lyrics matching the query, ignoring case,
+// highlights the matches and jumps to the first. An empty query clears the
+// search.
+func (m *model) searchLyrics(query string) tea.Cmd {
+	m.search = searchState{}
+	if query == "" {
+		m.updateLyrics(m.lyrics)
+		return nil
+	}
+
+	pattern := regexp.MustCompile("(?i)" + regexp.QuoteMeta(query))
+	var matches []int
+	for i, line := range strings.Split(m.lyrics, "\n") {
+		if pattern.MatchString(line) {
+			matches = append(matches, i)
+		}
+	}
+	if len(matches) == 0 {
+		m.updateLyrics(m.lyrics)
+		return m.flashFooterMessage("Pattern not found: " + query)
+	}
+
+	m.search = searchState{query: query, pattern: pattern, matches: matches}
+	m.updateLyrics(m.lyrics)
+	return m.jumpToMatch(0)
+}
+
+// clearSearch ends the search and removes the highlights
+func (m *model) clearSearch() {
+	m.search = searchState{}
+	m.updateLyrics(m.lyrics)
+}
+
+// jumpToMatch scrolls to the match a number of matches away from the current
+// one, wrapping around at either end
+func (m *model) jumpToMatch(delta int) tea.Cmd {
+	if m.state != "" || m.showingAnnotation {
+		return nil
+	}
+
+	n := len(m.search.matches)
+	m.search.current = ((m.search.current+delta)%n + n) % n
+
+	// Auto-scrolling holds off, as for any other scrolling
+	m.lastManualScroll = time.Now()
+	line := m.search.matches[m.search.current]
+	m.viewport.SetYOffset(m.lyricRow(line) - m.viewport.Height/2)
+
+	return m.flashFooterMessage(fmt.Sprintf("/%s [%d/%d]", m.search.query, m.search.current+1, n))
+}
+
+// highlightMatches styles every match of the search in the lyrics
+func (m *model) highlightMatches(lyrics string) string {
+	style := lipgloss.NewStyle().Reverse(true)
+	return m.search.pattern.ReplaceAllStringFunc(lyrics, func(match string) string {
+		return style.Render(match)
+	})
+}
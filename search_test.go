@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// searchLyricsFixture has a match for "needle" on lines 5, 15 and 25, too far
+// apart to be on screen together
+func searchLyricsFixture() string {
+	var lines []string
+	for i := 0; i < 30; i++ {
+		if i%10 == 5 {
+			lines = append(lines, fmt.Sprintf("A needle on line %d", i))
+		} else {
+			lines = append(lines, fmt.Sprintf("Line %d", i))
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// pressKey sends a key press to the model
+func pressKey(t *testing.T, m model, key string) model {
+	t.Helper()
+	return update(t, m, tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(key)})
+}
+
+// visibleText returns the lyrics on screen without styling
+func visibleText(m model) string {
+	return ansiPattern.ReplaceAllString(m.viewport.View(), "")
+}
+
+func TestSearchSurvivesResize(t *testing.T) {
+	withColorProfile(t)
+	m := withSong(newTestModel(40, 12), "Artist", "Album", "Title")
+	m = update(t, m, songLyricsMsg{artist: "Artist", album: "Album", title: "Title", lyrics: searchLyricsFixture()})
+
+	m.searchLyrics("needle")
+	if got := m.search.matches; len(got) != 3 {
+		t.Fatalf("matches = %v, want lines 5, 15 and 25", got)
+	}
+
+	m = update(t, m, tea.WindowSizeMsg{Width: 60, Height: 14})
+
+	if !m.search.active() || m.search.query != "needle" {
+		t.Fatalf("search = %+v after resizing, want it kept", m.search)
+	}
+	if !strings.Contains(m.viewport.View(), "\x1b[7mneedle") {
+		t.Errorf("match isn't highlighted after resizing:\n%s", m.viewport.View())
+	}
+
+	// n and N still step through the matches, wrapping around at the ends
+	steps := []struct {
+		key         string
+		wantCurrent int
+	}{
+		{"n", 1},
+		{"n", 2},
+		{"n", 0},
+		{"N", 2},
+	}
+	for _, step := range steps {
+		m = pressKey(t, m, step.key)
+		if m.search.current != step.wantCurrent {
+			t.Fatalf("after %s, current match = %d, want %d", step.key, m.search.current, step.wantCurrent)
+		}
+		line := fmt.Sprintf("A needle on line %d", m.search.matches[m.search.current])
+		if !strings.Contains(visibleText(m), line) {
+			t.Errorf("after %s, %q isn't on screen:\n%s", step.key, line, visibleText(m))
+		}
+	}
+}
+
+func TestSearchKeysDoNotOpenNotes(t *testing.T) {
+	m := withSong(newTestModel(40, 12), "Artist", "Album", "Title")
+	m = update(t, m, songLyricsMsg{artist: "Artist", album: "Album", title: "Title", lyrics: searchLyricsFixture()})
+	m.notes = map[string]string{m.currentSongID: "A note"}
+
+	for _, key := range []string{"n", "N"} {
+		m = pressKey(t, m, key)
+		if m.showingAnnotation || m.editField != "" {
+			t.Fatalf("%s opened the note without an active search", key)
+		}
+	}
+
+	m = pressKey(t, m, "o")
+	if !m.showingAnnotation || !strings.Contains(visibleText(m), "A note") {
+		t.Errorf("o didn't show the note:\n%s", visibleText(m))
+	}
+}
+
+func TestLyricRowWithVerticalCenter(t *testing.T) {
+	for _, lineNumbers := range []bool{false, true} {
+		m := newTestModel(40, 20)
+		m.verticalCenter = true
+		m.showLineNumbers = lineNumbers
+		m = withSong(m, "Artist", "Album", "Title")
+		m = update(t, m, songLyricsMsg{artist: "Artist", album: "Album", title: "Title", lyrics: "First\nSecond\nThird"})
+
+		rows := strings.Split(visibleText(m), "\n")
+		for line, want := range []string{"First", "Second", "Third"} {
+			row := m.lyricRow(line)
+			if row >= len(rows) || !strings.Contains(rows[row], want) {
+				t.Errorf("line numbers %v: lyricRow(%d) = %d, not the row showing %q:\n%s", lineNumbers, line, row, want, visibleText(m))
+			}
+		}
+	}
+}
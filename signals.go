@@ -0,0 +1,44 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// shutdownTimeout is how long a mode has to shut down cleanly after a
+// termination signal before the process exits anyway
+const shutdownTimeout = 3 * time.Second
+
+// signalContext returns a context that is cancelled on SIGINT or SIGTERM, for
+// the modes that run without the TUI. The TUI handles these signals itself.
+// If shutdown takes longer than shutdownTimeout, or a second signal arrives,
+// the process exits immediately.
+func signalContext() (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	sigs := make(chan os.Signal, 2)
+	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
+
+	go func() {
+		select {
+		case <-sigs:
+			cancel()
+		case <-ctx.Done():
+			signal.Stop(sigs)
+			return
+		}
+
+		select {
+		case <-sigs:
+		case <-time.After(shutdownTimeout):
+		}
+		fmt.Fprintln(os.Stderr, "Forced exit: shutdown took too long")
+		os.Exit(1)
+	}()
+
+	return ctx, cancel
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/charmbracelet/bubbles/spinner"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// States shown in place of lyrics. They are also the keys of the
+// state_messages and state_colors settings.
+const (
+	stateNoSong    = "no_song"
+	stateLoading   = "loading"
+	stateError     = "error"
+	stateEmpty     = "empty"
+	stateCancelled = "cancelled"
+)
+
+// defaultStateMessages are used for states without a configured message. An
+// "{error}" placeholder is replaced by the error or the cmus status.
+var defaultStateMessages = map[string]string{
+	stateNoSong:    "No song playing",
+	stateLoading:   "Loading...",
+	stateError:     "{error}",
+	stateEmpty:     "No lyrics found",
+	stateCancelled: "Cancelled. Press r to retry.",
+}
+
+// isState reports whether name is one of the states
+func isState(name string) bool {
+	_, ok := defaultStateMessages[name]
+	return ok
+}
+
+// stateStyle is the configured appearance of the states
+type stateStyle struct {
+	messages map[string]string
+	colors   map[string]lipgloss.Color
+}
+
+// newStateStyle merges the configured messages over the defaults. States
+// without a configured color use the palette's error color for errors and
+// the default foreground otherwise.
+func newStateStyle(messages, colors map[string]string, p palette) stateStyle {
+	s := stateStyle{
+		messages: make(map[string]string, len(defaultStateMessages)),
+		colors:   map[string]lipgloss.Color{stateError: p.errorFg},
+	}
+	for state, message := range defaultStateMessages {
+		s.messages[state] = message
+	}
+	for state, message := range messages {
+		s.messages[state] = message
+	}
+	for state, color := range colors {
+		s.colors[state] = lipgloss.Color(color)
+	}
+	return s
+}
+
+// text returns the message for the state, filling in the detail
+func (s stateStyle) text(state, detail string) string {
+	return strings.ReplaceAll(s.messages[state], "{error}", detail)
+}
+
+// showState replaces the lyrics with the message for the state, centered like
+// the lyrics. The state is kept so that it can be redrawn on resize.
+func (m *model) showState(state, detail string) {
+	m.state = state
+	m.stateDetail = detail
+	if state != stateLoading {
+		m.spinning = false
+	}
+	m.viewport.SetContent(m.stateContent(state, detail))
+}
+
+// stateContent renders the message for a state, centered in the viewport
+func (m *model) stateContent(state, detail string) string {
+	style := lipgloss.NewStyle().
+		Width(m.viewport.Width).
+		Align(lipgloss.Center).
+		Bold(m.boldLyrics)
+	if color, ok := m.stateStyle.colors[state]; ok {
+		style = style.Foreground(color)
+	}
+
+	text := m.stateStyle.text(state, detail)
+	if state == stateLoading {
+		text = m.spinner.View() + " " + text
+	}
+
+	var lines []string
+	for _, line := range strings.Split(text, "\n") {
+		if strings.TrimSpace(line) == "" {
+			lines = append(lines, "")
+			continue
+		}
+		lines = append(lines, strings.TrimRight(style.Render(line), " "))
+	}
+	content := strings.Join(lines, "\n")
+
+	if m.verticalCenter {
+		if height := lipgloss.Height(content); height < m.viewport.Height {
+			content = strings.Repeat("\n", (m.viewport.Height-height)/2) + content
+		}
+	}
+	return content
+}
+
+// newLoadingSpinner returns the spinner shown with the loading message
+func newLoadingSpinner() spinner.Model {
+	return spinner.New(spinner.WithSpinner(spinner.Dot))
+}
+
+// startSpinner starts animating the spinner when the loading state is shown
+// and it isn't already running
+func (m *model) startSpinner() tea.Cmd {
+	if m.state != stateLoading || m.spinning {
+		return nil
+	}
+	m.spinning = true
+	return m.spinner.Tick
+}
+
+// updateSpinner advances the spinner while loading. Once the lyrics or
+// another state are shown the tick is dropped, which stops the animation.
+func (m *model) updateSpinner(msg spinner.TickMsg) tea.Cmd {
+	if m.state != stateLoading {
+		m.spinning = false
+		return nil
+	}
+
+	var cmd tea.Cmd
+	m.spinner, cmd = m.spinner.Update(msg)
+	m.showState(m.state, m.stateDetail)
+	return cmd
+}
+
+// redraw renders the current state or lyrics again, such as after the window
+// is resized or a display setting is toggled
+func (m *model) redraw() {
+	if m.state != "" {
+		m.showState(m.state, m.stateDetail)
+	} else {
+		m.updateLyrics(m.lyrics)
+	}
+}
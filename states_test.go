@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/charmbracelet/bubbles/spinner"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func TestCancelledStateSurvivesSpinnerTick(t *testing.T) {
+	m := newTestModel(40, 10)
+	m.showState(stateLoading, "")
+	_, m.cancelFetch = context.WithCancel(context.Background())
+
+	m = update(t, m, tea.KeyMsg{Type: tea.KeyEsc})
+	if m.state != stateCancelled {
+		t.Fatalf("state = %q after esc, want %q", m.state, stateCancelled)
+	}
+	if m.spinning {
+		t.Error("spinner still running after leaving the loading state")
+	}
+
+	m = update(t, m, m.spinner.Tick())
+	m = update(t, m, tea.WindowSizeMsg{Width: 50, Height: 10})
+	if view := m.viewport.View(); !strings.Contains(view, "Cancelled. Press r to retry.") {
+		t.Errorf("viewport = %q, want the cancelled message", view)
+	}
+}
+
+func TestSpinnerOnlyAnimatesWhileLoading(t *testing.T) {
+	tests := []struct {
+		state    string
+		wantTick bool
+	}{
+		{stateLoading, true},
+		{stateNoSong, false},
+		{stateError, false},
+		{stateEmpty, false},
+		{stateCancelled, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.state, func(t *testing.T) {
+			m := newTestModel(40, 10)
+			m.showState(tt.state, "")
+			if cmd := m.updateSpinner(m.spinner.Tick().(spinner.TickMsg)); (cmd != nil) != tt.wantTick {
+				t.Errorf("next tick scheduled = %v, want %v", cmd != nil, tt.wantTick)
+			}
+		})
+	}
+}
+
+func TestCancelledRefreshKeepsLyrics(t *testing.T) {
+	m := withSong(newTestModel(40, 10), "Artist", "Album", "Title")
+	m = update(t, m, songLyricsMsg{artist: "Artist", album: "Album", title: "Title", lyrics: "First line"})
+
+	// A refresh is in flight for the lyrics already shown
+	_, m.cancelFetch = context.WithCancel(context.Background())
+	m = update(t, m, tea.KeyMsg{Type: tea.KeyEsc})
+
+	if m.cancelFetch != nil {
+		t.Error("fetch not cancelled")
+	}
+	if m.state != "" {
+		t.Errorf("state = %q, want the lyrics kept", m.state)
+	}
+	if view := m.viewport.View(); !strings.Contains(view, "First line") {
+		t.Errorf("viewport = %q, want the lyrics", view)
+	}
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"regexp"
+	"strconv"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/pkg/errors"
+)
+
+// palette holds the colors used to render the UI
+type palette struct {
+	statusBarFg lipgloss.Color
+	statusBarBg lipgloss.Color
+	footer      lipgloss.Color
+	errorFg     lipgloss.Color
+
+	// Color of the line being sung in synced lyrics
+	highlightFg lipgloss.Color
+}
+
+// darkPalette is used on terminals with a dark background
+var darkPalette = palette{
+	statusBarFg: lipgloss.Color("#FFFFFF"),
+	statusBarBg: lipgloss.Color("#0088CC"),
+	footer:      lipgloss.Color("#626262"),
+	errorFg:     lipgloss.Color("#FF5F5F"),
+	highlightFg: lipgloss.Color("#5FD7FF"),
+}
+
+// lightPalette is used on terminals with a light background
+var lightPalette = palette{
+	statusBarFg: lipgloss.Color("#FFFFFF"),
+	statusBarBg: lipgloss.Color("#006699"),
+	footer:      lipgloss.Color("#8A8A8A"),
+	errorFg:     lipgloss.Color("#D70000"),
+	highlightFg: lipgloss.Color("#005F87"),
+}
+
+// themePresets are built-in palettes that can be selected by name with
+// theme.preset
+var themePresets = map[string]palette{
+	"dark":  darkPalette,
+	"light": lightPalette,
+	"solarized": {
+		statusBarFg: lipgloss.Color("#FDF6E3"),
+		statusBarBg: lipgloss.Color("#268BD2"),
+		footer:      lipgloss.Color("#586E75"),
+		errorFg:     lipgloss.Color("#DC322F"),
+		highlightFg: lipgloss.Color("#B58900"),
+	},
+	"gruvbox": {
+		statusBarFg: lipgloss.Color("#282828"),
+		statusBarBg: lipgloss.Color("#D79921"),
+		footer:      lipgloss.Color("#928374"),
+		errorFg:     lipgloss.Color("#FB4934"),
+		highlightFg: lipgloss.Color("#8EC07C"),
+	},
+	"nord": {
+		statusBarFg: lipgloss.Color("#2E3440"),
+		statusBarBg: lipgloss.Color("#88C0D0"),
+		footer:      lipgloss.Color("#4C566A"),
+		errorFg:     lipgloss.Color("#BF616A"),
+		highlightFg: lipgloss.Color("#EBCB8B"),
+	},
+}
+
+// ThemeConfig overrides the colors of the palette. Colors are hex values
+// such as "#0088CC" or ANSI color numbers.
+type ThemeConfig struct {
+	// Preset is the name of a built-in palette that's used instead of the
+	// color scheme's, see themePresets
+	Preset string `json:"preset"`
+
+	StatusBarFg string `json:"status_bar_fg"`
+	StatusBarBg string `json:"status_bar_bg"`
+	Footer      string `json:"footer"`
+
+	// Accent is the color of the line being sung in synced lyrics
+	Accent string `json:"accent"`
+}
+
+// hexColorPattern matches a hex color such as "#0088CC" or "#08C"
+var hexColorPattern = regexp.MustCompile(`^#(?:[0-9a-fA-F]{3}|[0-9a-fA-F]{6})$`)
+
+// isValidColor reports whether s is a hex color or an ANSI color number
+func isValidColor(s string) bool {
+	if hexColorPattern.MatchString(s) {
+		return true
+	}
+	n, err := strconv.Atoi(s)
+	return err == nil && n >= 0 && n <= 255
+}
+
+// validate checks the preset name and that every color set is valid
+func (t ThemeConfig) validate() error {
+	if _, ok := themePresets[t.Preset]; t.Preset != "" && !ok {
+		return errors.Errorf("invalid theme.preset %q: must be dark, light, solarized, gruvbox, or nord", t.Preset)
+	}
+
+	fields := []struct {
+		name  string
+		value string
+	}{
+		{"status_bar_fg", t.StatusBarFg},
+		{"status_bar_bg", t.StatusBarBg},
+		{"footer", t.Footer},
+		{"accent", t.Accent},
+	}
+	for _, field := range fields {
+		if field.value != "" && !isValidColor(field.value) {
+			return errors.Errorf("invalid theme.%s %q: must be a hex color such as \"#0088CC\" or an ANSI color number", field.name, field.value)
+		}
+	}
+	return nil
+}
+
+// apply returns the palette with the theme's preset and colors applied
+func (t ThemeConfig) apply(p palette) palette {
+	if preset, ok := themePresets[t.Preset]; ok {
+		p = preset
+	}
+
+	overrides := []struct {
+		value string
+		color *lipgloss.Color
+	}{
+		{t.StatusBarFg, &p.statusBarFg},
+		{t.StatusBarBg, &p.statusBarBg},
+		{t.Footer, &p.footer},
+		{t.Accent, &p.highlightFg},
+	}
+	for _, override := range overrides {
+		if override.value != "" {
+			*override.color = lipgloss.Color(override.value)
+		}
+	}
+	return p
+}
+
+// selectPalette returns the palette for the given color scheme. For "auto"
+// (or an empty value) the terminal background is detected.
+func selectPalette(colorScheme string) palette {
+	switch colorScheme {
+	case "dark":
+		return darkPalette
+	case "light":
+		return lightPalette
+	default:
+		if lipgloss.HasDarkBackground() {
+			return darkPalette
+		}
+		return lightPalette
+	}
+}
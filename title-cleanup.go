@@ -0,0 +1,44 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+)
+
+// titleCleanupRules strip descriptors from titles and album names that trip
+// up the search, such as featured artists and remaster notes. They're
+// applied in order, each removing what it matches.
+var titleCleanupRules = []*regexp.Regexp{
+	// "(feat. Someone)", "[ft. Someone]", "(with Someone)"
+	regexp.MustCompile(`(?i)\s*[(\[](?:feat\.?|ft\.?|featuring|with)\s[^)\]]*[)\]]`),
+
+	// "feat. Someone" without brackets, which runs to the end
+	regexp.MustCompile(`(?i)\s+(?:feat\.|ft\.|featuring)\s.*$`),
+
+	// "(2011 Remaster)", "[Remastered]", "(Deluxe Edition)", "(Live at
+	// Wembley)", "(Mono)"
+	regexp.MustCompile(`(?i)\s*[(\[][^)\]]*\b(?:remaster(?:ed)?|deluxe|live|mono|stereo|anniversary)\b[^)\]]*[)\]]`),
+
+	// " - 2011 Remaster", " - Remastered 2009", " - Live at Venue", " - Mono
+	// Version"
+	regexp.MustCompile(`(?i)\s+[-–]\s+(?:\d{4}\s+)?(?:remaster(?:ed)?|deluxe|live|mono|stereo|single version|radio edit)\b.*$`),
+
+	// A trailing year, "(1999)" or " - 1999"
+	regexp.MustCompile(`\s*(?:\(\d{4}\)|\[\d{4}\]|\s[-–]\s\d{4})$`),
+}
+
+// cleanTitle removes featured artists, remaster and edition notes, and year
+// suffixes from a title or album name before it's searched for. The title is
+// returned unchanged if nothing would be left.
+func cleanTitle(title string) string {
+	cleaned := title
+	for _, rule := range titleCleanupRules {
+		cleaned = rule.ReplaceAllString(cleaned, "")
+	}
+
+	cleaned = strings.TrimSpace(cleaned)
+	if cleaned == "" {
+		return title
+	}
+	return cleaned
+}
@@ -0,0 +1,48 @@
+package main
+
+import "testing"
+
+func TestCleanTitle(t *testing.T) {
+	tests := []struct {
+		title string
+		want  string
+	}{
+		{"Paranoid Android", "Paranoid Android"},
+		{"Empire State of Mind (feat. Alicia Keys)", "Empire State of Mind"},
+		{"Stay [ft. Justin Bieber]", "Stay"},
+		{"Fake Love (with Drake)", "Fake Love"},
+		{"Get Lucky feat. Pharrell Williams & Nile Rodgers", "Get Lucky"},
+		{"Under Pressure (Remastered 2011)", "Under Pressure"},
+		{"Here Comes the Sun - Remastered 2009", "Here Comes the Sun"},
+		{"Song Title (feat. Someone) - 2011 Remaster", "Song Title"},
+		{"Heroes - 2017 Remaster", "Heroes"},
+		{"Bohemian Rhapsody - Live Aid", "Bohemian Rhapsody"},
+		{"Wonderwall (Live at Knebworth)", "Wonderwall"},
+		{"Eleanor Rigby - Mono Version", "Eleanor Rigby"},
+		{"Dreams - 2004 Remaster", "Dreams"},
+		{"Purple Rain (Deluxe Edition)", "Purple Rain"},
+		{"Rumours (Super Deluxe)", "Rumours"},
+		{"Abbey Road (50th Anniversary Edition)", "Abbey Road"},
+		{"1999 (1999)", "1999"},
+		{"Summer of '69 - 1984", "Summer of '69"},
+		{"Bad Guy - Radio Edit", "Bad Guy"},
+
+		// Parentheticals that are part of the title are kept
+		{"(Don't Fear) The Reaper", "(Don't Fear) The Reaper"},
+		{"Wouldn't It Be Nice (Stereo Mix)", "Wouldn't It Be Nice"},
+		{"Live Forever", "Live Forever"},
+		{"With or Without You", "With or Without You"},
+
+		// A title that is all descriptor is left alone
+		{"(Remastered)", "(Remastered)"},
+		{"1984", "1984"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.title, func(t *testing.T) {
+			if got := cleanTitle(tt.title); got != tt.want {
+				t.Errorf("cleanTitle(%q) = %q, want %q", tt.title, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/pkg/errors"
+)
+
+// viewLyrics are shown in the golden View tests. They're long enough to
+// scroll in the shorter terminals, with lines that wrap in the narrower ones.
+const viewLyrics = `[Verse 1]
+I'd like to stay a little longer
+Underneath the city lights
+Every night we're running
+
+[Chorus]
+Oh, we're burning brighter than the stars above us
+Than the stars above us
+
+[Verse 2]
+Morning comes too early`
+
+// viewScenarios set up the model for each state rendered in the golden
+// View tests
+var viewScenarios = []struct {
+	name  string
+	setup func(t *testing.T, m model) model
+}{
+	{"loading", func(t *testing.T, m model) model {
+		return m
+	}},
+	{"lyrics", func(t *testing.T, m model) model {
+		return update(t, m, songLyricsMsg{artist: "Artist", album: "Album", title: "Title", lyrics: viewLyrics})
+	}},
+	{"lyrics-progress", func(t *testing.T, m model) model {
+		m.position, m.duration = 37, 215
+		return update(t, m, songLyricsMsg{artist: "Artist", album: "Album", title: "Title", lyrics: viewLyrics})
+	}},
+	{"error", func(t *testing.T, m model) model {
+		return update(t, m, songLyricsMsg{artist: "Artist", album: "Album", title: "Title", err: errors.New("unexpected status code: 500")})
+	}},
+	{"help-footer", func(t *testing.T, m model) model {
+		m.showHelpFooter = true
+		return update(t, m, songLyricsMsg{artist: "Artist", album: "Album", title: "Title", lyrics: viewLyrics})
+	}},
+}
+
+// viewWidths are the terminal widths the golden View tests render at, from a
+// narrow split pane to a full screen
+var viewWidths = []int{30, 60, 100}
+
+func TestViewGolden(t *testing.T) {
+	helpText, compactHelpText := buildHelpText(defaultKeybindings)
+
+	for _, scenario := range viewScenarios {
+		for _, width := range viewWidths {
+			name := fmt.Sprintf("%s-%d", scenario.name, width)
+			t.Run(name, func(t *testing.T) {
+				m := newTestModel(width, 12)
+				m.helpText, m.compactHelpText = helpText, compactHelpText
+				m.showSectionHeaders = true
+				m.playbackStatus = statusPlaying
+				m = withSong(m, "Artist", "Album", "Title")
+				m.updateStatusBar()
+				m = scenario.setup(t, m)
+
+				view := m.View()
+				for i, line := range strings.Split(view, "\n") {
+					if w := lipgloss.Width(line); w > width {
+						t.Errorf("line %d is %d columns wide, wider than the terminal: %q", i, w, line)
+					}
+				}
+				checkGolden(t, filepath.Join("testdata", "view", name+".golden"), view)
+			})
+		}
+	}
+}